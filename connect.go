@@ -13,13 +13,22 @@ import (
 	"github.com/uptrace/bun/schema"
 )
 
-// NewDBConnection establishes a connection to the database.
+// NewDBConnection establishes a connection to the database. SQLite
+// connections get a REGEXP function backed by Go's regexp package.
 func NewDBConnection(driver DBDriver, dataSourceName string, poolMax int, printQueries bool) (*bun.DB, error) {
 	if !driver.IsValid() {
 		return nil, fmt.Errorf("unknown database driver %s", driver.String())
 	}
 
-	conn, err := sql.Open(driver.String(), dataSourceName)
+	driverName := driver.String()
+	if driver == DriverSqlite {
+		var err error
+		if driverName, err = sqliteDriverName(); err != nil {
+			return nil, err
+		}
+	}
+
+	conn, err := sql.Open(driverName, dataSourceName)
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to database: %w", err)
 	}
@@ -0,0 +1,145 @@
+package filter
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// The array operators filter on array columns, e.g a `text[]` of tags
+// tagged `bun:",array"`, with the Postgres array operators. On SQLite,
+// which stores such a field as a JSON array, they are emulated with
+// json_each.
+
+// ArrayOverlaps matches an array column sharing at least one item with
+// values.
+func ArrayOverlaps[T any](columnName string, values []T) *FieldCondition {
+	return newArrayStmt(OpArrayOverlaps, columnName, values)
+}
+
+// ArrayContainsAny matches an array column containing any of the values.
+// It is ArrayOverlaps.
+func ArrayContainsAny[T any](columnName string, values []T) *FieldCondition {
+	return newArrayStmt(OpArrayContainsAny, columnName, values)
+}
+
+// ArrayContainsAll matches an array column containing all the values.
+func ArrayContainsAll[T any](columnName string, values []T) *FieldCondition {
+	return newArrayStmt(OpArrayContainsAll, columnName, values)
+}
+
+// ArrayLength compares the number of items of an array column with n,
+// using a comparison operator, e.g OpGreaterThan. It panics on any other
+// operator.
+func ArrayLength(columnName string, op Operator, n int) *FieldCondition {
+	cmp, ok := comparisons[op]
+	if !ok {
+		panic(fmt.Sprintf("operator %q is not a comparison", op))
+	}
+
+	return &FieldCondition{
+		stmt:       "?",
+		columnName: columnName,
+		expr: func(column bun.Ident) schema.QueryAppender {
+			return &arrayLengthExpr{column: column, cmp: cmp, n: n}
+		},
+		op:    OpArrayLength,
+		value: []any{op, n},
+	}
+}
+
+func newArrayStmt[T any](op Operator, columnName string, values []T) *FieldCondition {
+	if len(values) == 0 {
+		return nil
+	}
+
+	return &FieldCondition{
+		stmt:       "?",
+		columnName: columnName,
+		expr: func(column bun.Ident) schema.QueryAppender {
+			return &arrayExpr{column: column, all: op == OpArrayContainsAll, values: values, distinct: countDistinct(values)}
+		},
+		op:    op,
+		value: values,
+	}
+}
+
+type arrayExpr struct {
+	column   bun.Ident
+	all      bool
+	values   any
+	distinct int
+}
+
+func (e *arrayExpr) AppendQuery(fmter schema.Formatter, b []byte) ([]byte, error) {
+	switch {
+	case fmter.Dialect().Name() == dialect.PG && e.all:
+		return fmter.AppendQuery(b, "? @> ?", e.column, pgArray(e.values)), nil
+	case fmter.Dialect().Name() == dialect.PG:
+		return fmter.AppendQuery(b, "? && ?", e.column, pgArray(e.values)), nil
+	case e.all:
+		return fmter.AppendQuery(b,
+			"(SELECT COUNT(DISTINCT json_each.value) FROM json_each(?) WHERE json_each.value IN (?)) = ?",
+			e.column, bun.In(e.values), e.distinct), nil
+	}
+	return fmter.AppendQuery(b, "EXISTS (SELECT 1 FROM json_each(?) WHERE json_each.value IN (?))", e.column, bun.In(e.values)), nil
+}
+
+// pgArray renders values as a Postgres array. The []any the parsers build
+// is converted to a slice of the type its elements share, e.g a []string,
+// as pgdialect.Array cannot render it, and mixed elements are listed in an
+// ARRAY constructor.
+func pgArray(values any) schema.QueryAppender {
+	list, ok := values.([]any)
+	if !ok {
+		return pgdialect.Array(values)
+	}
+
+	if len(list) > 0 && list[0] != nil {
+		typ := reflect.TypeOf(list[0])
+		typed := reflect.MakeSlice(reflect.SliceOf(typ), len(list), len(list))
+		for i, v := range list {
+			if reflect.TypeOf(v) != typ {
+				return &queryExpr{query: "ARRAY[?]", args: []any{bun.In(list)}}
+			}
+			typed.Index(i).Set(reflect.ValueOf(v))
+		}
+		return pgdialect.Array(typed.Interface())
+	}
+	return &queryExpr{query: "ARRAY[?]", args: []any{bun.In(list)}}
+}
+
+type arrayLengthExpr struct {
+	column bun.Ident
+	cmp    string
+	n      int
+}
+
+func (e *arrayLengthExpr) AppendQuery(fmter schema.Formatter, b []byte) ([]byte, error) {
+	if fmter.Dialect().Name() == dialect.PG {
+		return fmter.AppendQuery(b, "cardinality(?) "+e.cmp+" ?", e.column, e.n), nil
+	}
+	return fmter.AppendQuery(b, "json_array_length(?) "+e.cmp+" ?", e.column, e.n), nil
+}
+
+// countDistinct counts the distinct values. Values that cannot be map
+// keys, e.g slices, are compared with reflect.DeepEqual.
+func countDistinct[T any](values []T) int {
+	seen := make(map[any]bool, len(values))
+	var unhashable []any
+	for _, v := range values {
+		if rv := reflect.ValueOf(v); !rv.IsValid() || rv.Comparable() {
+			seen[any(v)] = true
+			continue
+		}
+		if !slices.ContainsFunc(unhashable, func(u any) bool { return reflect.DeepEqual(u, v) }) {
+			unhashable = append(unhashable, v)
+		}
+	}
+	return len(seen) + len(unhashable)
+}
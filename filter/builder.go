@@ -0,0 +1,222 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// ErrInvalidDirection is returned for an order direction other than
+// "asc" or "desc".
+var ErrInvalidDirection = errors.New("invalid order direction: should be 'asc' or 'desc'")
+
+// ParseDirection normalizes an order direction to "asc" or "desc".
+func ParseDirection(direction string) (string, error) {
+	switch value := strings.ToLower(strings.TrimSpace(direction)); value {
+	case "asc", "desc":
+		return value, nil
+	}
+	return "", ErrInvalidDirection
+}
+
+// Builder adds filters to a query like Where, OrWhere, OrderBy and Limit do,
+// but collects errors rather than panicking. Columns are checked against the
+// table of the query's model, so a column supplied by a client that does not
+// exist becomes an error to report rather than a failing query.
+//
+//	b := filter.NewBuilder(q).
+//		Where(filter.Eq(column, value)).
+//		OrderBy(sortColumn, sortDirection)
+//	if err := b.Err(); err != nil {
+//		// respond with 400 Bad Request
+//	}
+type Builder[T allBunQueryType] struct {
+	q      T
+	table  *schema.Table
+	joined map[string]bool
+	errs   ValidationErrors
+}
+
+// NewBuilder creates a builder for the query. Columns are only checked
+// when the query has a model.
+func NewBuilder[T allBunQueryType](q T) *Builder[T] {
+	return &Builder[T]{q: q, table: queryTable(q), joined: make(map[string]bool)}
+}
+
+// Where adds the condition joined with "AND", if its columns are valid.
+// Relations are joined once per path, see Where.
+func (b *Builder[T]) Where(cond Condition) *Builder[T] {
+	if cond, ok := b.resolve(cond); ok {
+		Where(b.q, cond)
+	}
+	return b
+}
+
+// OrWhere adds the condition joined with "OR", if its columns are valid.
+func (b *Builder[T]) OrWhere(cond Condition) *Builder[T] {
+	if cond, ok := b.resolve(cond); ok {
+		OrWhere(b.q, cond)
+	}
+	return b
+}
+
+// OrderBy orders a select query by the column. An empty direction is
+// skipped, as with OrderBy.
+func (b *Builder[T]) OrderBy(column, direction string) *Builder[T] {
+	if strings.TrimSpace(direction) == "" {
+		return b
+	}
+
+	q, ok := any(b.q).(*bun.SelectQuery)
+	if !ok {
+		b.errs = append(b.errs, &FieldError{Param: column, Message: "order by only works with Select Query"})
+		return b
+	}
+
+	direction, err := ParseDirection(direction)
+	if err != nil {
+		b.errs = append(b.errs, &FieldError{Param: column, Message: err.Error()})
+		return b
+	}
+
+	if b.checkColumn(column) {
+		OrderBy(q, column, direction)
+	}
+	return b
+}
+
+// Sort orders a select query by the spec, if its columns are valid.
+func (b *Builder[T]) Sort(spec SortSpec) *Builder[T] {
+	q, ok := any(b.q).(*bun.SelectQuery)
+	if !ok {
+		b.errs = append(b.errs, &FieldError{Param: "sort", Message: "sort only works with Select Query"})
+		return b
+	}
+
+	valid := true
+	for _, f := range spec {
+		valid = b.checkColumn(f.Column) && valid
+	}
+	if valid {
+		spec.Apply(q)
+	}
+	return b
+}
+
+// Limit limits a select query.
+func (b *Builder[T]) Limit(limit int) *Builder[T] {
+	q, ok := any(b.q).(*bun.SelectQuery)
+	switch {
+	case !ok:
+		b.errs = append(b.errs, &FieldError{Param: "limit", Message: "limit only works with Select Query"})
+	case limit < 0:
+		b.errs = append(b.errs, &FieldError{Param: "limit", Message: "must be a non-negative integer"})
+	default:
+		Limit(q, limit)
+	}
+	return b
+}
+
+// Query returns the query being built.
+func (b *Builder[T]) Query() T {
+	return b.q
+}
+
+// Err returns the ValidationErrors collected so far, or nil.
+func (b *Builder[T]) Err() error {
+	if len(b.errs) == 0 {
+		return nil
+	}
+	return b.errs
+}
+
+// resolve checks the columns of the condition and joins its relations.
+func (b *Builder[T]) resolve(cond Condition) (Condition, bool) {
+	if isEmpty(cond) || !b.checkColumns(cond) {
+		return nil, false
+	}
+
+	cond, err := joinRelations(b.q, b.table, cond, b.joined)
+	if err != nil {
+		b.errs = append(b.errs, err)
+		return nil, false
+	}
+	return cond, true
+}
+
+func (b *Builder[T]) checkColumns(cond Condition) bool {
+	valid := true
+	for _, column := range conditionColumns(cond) {
+		// relation paths are checked by joinRelations
+		if isRelationPath(column) {
+			continue
+		}
+		valid = b.checkColumn(column) && valid
+	}
+	return valid
+}
+
+func (b *Builder[T]) checkColumn(column string) bool {
+	if b.table == nil || hasColumn(b.table, column) {
+		return true
+	}
+	b.errs = append(b.errs, &FieldError{Param: column, Message: fmt.Sprintf("%s has no column %q", b.table.TypeName, column)})
+	return false
+}
+
+// hasColumn reports whether column is a column of the table, or a column
+// qualified by the table alias or by the alias of one of its relations.
+func hasColumn(table *schema.Table, column string) bool {
+	alias, name, qualified := strings.Cut(column, ".")
+	if !qualified {
+		return table.HasField(column)
+	}
+	if alias == table.Alias {
+		return table.HasField(name)
+	}
+	for _, rel := range table.Relations {
+		if rel.Field.Name == alias {
+			return rel.JoinTable.HasField(name)
+		}
+	}
+	return false
+}
+
+// conditionColumns lists the columns a condition refers to.
+func conditionColumns(cond Condition) []string {
+	var columns []string
+	Inspect(cond, func(cond Condition) bool {
+		switch c := cond.(type) {
+		case *FieldCondition:
+			columns = append(columns, c.columnName)
+		case *Subquery:
+			if c.columnName != "" {
+				columns = append(columns, c.columnName)
+			}
+		case *FullTextSearch:
+			columns = append(columns, c.columns...)
+		}
+		return true
+	})
+	return columns
+}
+
+func queryTable[T allBunQueryType](bunQ T) *schema.Table {
+	var model bun.Model
+	switch q := any(bunQ).(type) {
+	case *bun.SelectQuery:
+		model = q.GetModel()
+	case *bun.UpdateQuery:
+		model = q.GetModel()
+	case *bun.DeleteQuery:
+		model = q.GetModel()
+	}
+
+	if tm, ok := model.(interface{ Table() *schema.Table }); ok {
+		return tm.Table()
+	}
+	return nil
+}
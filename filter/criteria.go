@@ -0,0 +1,79 @@
+package filter
+
+import (
+	dbstore "github.com/otyang/go-dbstore"
+	"github.com/uptrace/bun"
+)
+
+// SelectWhere adds the conditions to a select query, joined with "AND",
+// as a dbstore.SelectCriteria. It composes with other criteria given to
+// the repositories, e.g
+//
+//	repo.FindManyWhere(ctx, &books, nil,
+//		filter.SelectWhere(filter.Gte("year", 2020), filter.Eq("Author.name", "Ann")),
+//		filter.SortBy(spec),
+//		dbstore.WithRelations("Author"),
+//	)
+//
+// Relation columns are handled as with Where, and an unknown relation
+// path fails the query.
+func SelectWhere(conds ...Condition) dbstore.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		for _, cond := range conds {
+			Where(q, cond)
+		}
+		return q
+	}
+}
+
+// UpdateWhere adds the conditions to an update query, joined with "AND",
+// as a dbstore.UpdateCriteria.
+func UpdateWhere(conds ...Condition) dbstore.UpdateCriteria {
+	return func(q *bun.UpdateQuery) *bun.UpdateQuery {
+		for _, cond := range conds {
+			Where(q, cond)
+		}
+		return q
+	}
+}
+
+// DeleteWhere adds the conditions to a delete query, joined with "AND",
+// as a dbstore.DeleteCriteria.
+func DeleteWhere(conds ...Condition) dbstore.DeleteCriteria {
+	return func(q *bun.DeleteQuery) *bun.DeleteQuery {
+		for _, cond := range conds {
+			Where(q, cond)
+		}
+		return q
+	}
+}
+
+// SortBy orders a select query by the spec, as a dbstore.SelectCriteria.
+// With FindManyWhere, a PaginationOption orders by its cursor column
+// after it.
+func SortBy(spec SortSpec) dbstore.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		spec.Apply(q)
+		return q
+	}
+}
+
+// LimitTo limits a select query, as a dbstore.SelectCriteria. A limit
+// of zero is skipped. With FindManyWhere, the limit of a PaginationOption
+// replaces it.
+func LimitTo(limit int) dbstore.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		if limit > 0 {
+			Limit(q, limit)
+		}
+		return q
+	}
+}
+
+// Criteria is the parsed query as a dbstore.SelectCriteria, see Apply.
+func (pq *ParsedQuery) Criteria() dbstore.SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		pq.Apply(q)
+		return q
+	}
+}
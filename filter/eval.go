@@ -0,0 +1,604 @@
+package filter
+
+import (
+	"cmp"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dbstore "github.com/otyang/go-dbstore"
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/schema"
+)
+
+var (
+	_ dbstore.Specification = (*FieldCondition)(nil)
+	_ dbstore.Specification = (*Group)(nil)
+	_ dbstore.Specification = (*Negation)(nil)
+)
+
+var (
+	// modelTables maps models to their columns for Evaluate. Column names
+	// do not depend on the dialect.
+	modelTables = pgdialect.New().Tables()
+
+	// tableErrs holds the models modelTables failed on, which bun leaves
+	// half initialized.
+	tableErrs sync.Map // reflect.Type -> error
+)
+
+// RegisterModel registers the join models of many-to-many relations for
+// Evaluate, as db.RegisterModel does for queries, e.g
+//
+//	filter.RegisterModel((*WriterGenre)(nil))
+//
+// It must be called before a model with such a relation is evaluated.
+func RegisterModel(models ...any) {
+	modelTables.Register(models...)
+}
+
+// modelTable returns the table of the model type, or an error where bun
+// panics, e.g on a many-to-many relation whose join model is not
+// registered.
+func modelTable(typ reflect.Type) (table *schema.Table, err error) {
+	if err, ok := tableErrs.Load(typ); ok {
+		return nil, err.(error)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			stored, _ := tableErrs.LoadOrStore(typ, fmt.Errorf("%s: %v", typ, r))
+			table, err = nil, stored.(error)
+		}
+	}()
+	return modelTables.Get(typ), nil
+}
+
+// Evaluate reports whether a model, a struct or a pointer to one, satisfies
+// the condition, checking it in Go the way the database would. A comparison
+// with a NULL column, a nil pointer or slice, is unknown, and so is its
+// negation: neither is satisfied. Columns on a relation path, e.g
+// "Author.name", are read from the loaded relation.
+//
+// Strings compare byte-wise, which may differ from the collation of the
+// database, and the string operators ignore case with strings.ToLower.
+// Conditions built without an operator, e.g Search and subqueries, cannot
+// be evaluated and return an error, as do models with a many-to-many
+// relation whose join model was not registered with RegisterModel.
+func Evaluate(cond Condition, model any) (bool, error) {
+	v := reflect.Indirect(reflect.ValueOf(model))
+	if v.Kind() != reflect.Struct {
+		return false, fmt.Errorf("evaluate: expected a struct, got %T", model)
+	}
+	if isEmpty(cond) {
+		return true, nil
+	}
+
+	table, err := modelTable(v.Type())
+	if err != nil {
+		return false, fmt.Errorf("evaluate: %w", err)
+	}
+
+	t, err := evaluate(cond, table, v)
+	if err != nil {
+		return false, fmt.Errorf("evaluate: %w", err)
+	}
+	return t == truthTrue, nil
+}
+
+// Criteria adds the condition to a select query, as SelectWhere does.
+func (n *FieldCondition) Criteria() dbstore.SelectCriteria { return SelectWhere(n) }
+
+// Criteria adds the group to a select query, as SelectWhere does.
+func (g *Group) Criteria() dbstore.SelectCriteria { return SelectWhere(g) }
+
+// Criteria adds the negation to a select query, as SelectWhere does.
+func (n *Negation) Criteria() dbstore.SelectCriteria { return SelectWhere(n) }
+
+// IsSatisfiedBy reports whether the model satisfies the condition, see
+// Evaluate. Conditions that cannot be evaluated are not satisfied.
+func (n *FieldCondition) IsSatisfiedBy(model any) bool { return isSatisfiedBy(n, model) }
+
+// IsSatisfiedBy reports whether the model satisfies the group, see
+// Evaluate. Conditions that cannot be evaluated are not satisfied.
+func (g *Group) IsSatisfiedBy(model any) bool { return isSatisfiedBy(g, model) }
+
+// IsSatisfiedBy reports whether the model satisfies the negation, see
+// Evaluate. Conditions that cannot be evaluated are not satisfied.
+func (n *Negation) IsSatisfiedBy(model any) bool { return isSatisfiedBy(n, model) }
+
+func isSatisfiedBy(cond Condition, model any) bool {
+	ok, err := Evaluate(cond, model)
+	return err == nil && ok
+}
+
+// truth is the three-valued logic of SQL.
+type truth int8
+
+const (
+	truthFalse truth = iota
+	truthTrue
+	truthUnknown
+)
+
+func truthOf(b bool) truth {
+	if b {
+		return truthTrue
+	}
+	return truthFalse
+}
+
+func (t truth) and(o truth) truth {
+	switch {
+	case t == truthFalse || o == truthFalse:
+		return truthFalse
+	case t == truthUnknown || o == truthUnknown:
+		return truthUnknown
+	}
+	return truthTrue
+}
+
+func (t truth) or(o truth) truth {
+	switch {
+	case t == truthTrue || o == truthTrue:
+		return truthTrue
+	case t == truthUnknown || o == truthUnknown:
+		return truthUnknown
+	}
+	return truthFalse
+}
+
+func (t truth) not() truth {
+	if t == truthUnknown {
+		return truthUnknown
+	}
+	return truthOf(t == truthFalse)
+}
+
+func evaluate(cond Condition, table *schema.Table, v reflect.Value) (truth, error) {
+	switch c := cond.(type) {
+	case *FieldCondition:
+		return c.evaluate(table, v)
+	case *Group:
+		result := truthOf(!c.or)
+		for _, child := range c.conditions {
+			t, err := evaluate(child, table, v)
+			if err != nil {
+				return truthUnknown, err
+			}
+			if c.or {
+				result = result.or(t)
+			} else {
+				result = result.and(t)
+			}
+		}
+		return result, nil
+	case *Negation:
+		t, err := evaluate(c.condition, table, v)
+		return t.not(), err
+	}
+	return truthUnknown, fmt.Errorf("%T cannot be evaluated in Go", cond)
+}
+
+func (n *FieldCondition) evaluate(table *schema.Table, v reflect.Value) (truth, error) {
+	if n.op == "" {
+		return truthUnknown, fmt.Errorf("condition on %q has no operator to evaluate", n.columnName)
+	}
+
+	field, err := columnValue(table, v, n.columnName)
+	if err != nil {
+		return truthUnknown, err
+	}
+	switch {
+	case n.op == OpIsNull:
+		return truthOf((field == nil) == n.value.(bool)), nil
+	case n.op == OpJSONHasKey && field == nil:
+		// has no key, rather than unknown, as "IS NOT NULL" is
+		return truthFalse, nil
+	}
+	if field == nil {
+		return truthUnknown, nil
+	}
+
+	switch n.op {
+	case OpEqual, OpNotEqual, OpLessThan, OpLessThanOrEqual, OpGreaterThan, OpGreaterThanOrEqual:
+		c, err := compareValues(field, n.value)
+		if err != nil {
+			return truthUnknown, err
+		}
+		return truthOf(compared(n.op, c)), nil
+
+	case OpIn, OpNotIn:
+		in, err := containsValue(reflect.ValueOf(n.value), field)
+		if err != nil {
+			return truthUnknown, err
+		}
+		return truthOf(in == (n.op == OpIn)), nil
+
+	case OpContains, OpNotContains, OpStartsWith, OpNotStartsWith, OpEndsWith, OpNotEndsWith,
+		OpContainsCase, OpNotContainsCase, OpStartsWithCase, OpNotStartsWithCase, OpEndsWithCase, OpNotEndsWithCase:
+		s, ok := comparableValue(field).(string)
+		if !ok {
+			return truthUnknown, fmt.Errorf("operator %q expects a string column, %q is %T", n.op, n.columnName, field)
+		}
+		return truthOf(matchString(n.op, s, n.value.(string))), nil
+
+	case OpMatches, OpNotMatches, OpMatchesFold, OpNotMatchesFold:
+		s, ok := comparableValue(field).(string)
+		if !ok {
+			return truthUnknown, fmt.Errorf("operator %q expects a string column, %q is %T", n.op, n.columnName, field)
+		}
+		pattern := n.value.(string)
+		if n.op == OpMatchesFold || n.op == OpNotMatchesFold {
+			pattern = "(?i)" + pattern
+		}
+		matched, err := regexp.MatchString(pattern, s)
+		if err != nil {
+			return truthUnknown, err
+		}
+		return truthOf(matched == (n.op == OpMatches || n.op == OpMatchesFold)), nil
+
+	case OpBetween, OpNotBetween, OpInRange:
+		bounds := n.value.([]any)
+		in := true
+		if bounds[0] != nil {
+			c, err := compareValues(field, bounds[0])
+			if err != nil {
+				return truthUnknown, err
+			}
+			in = c >= 0
+		}
+		if bounds[1] != nil {
+			c, err := compareValues(field, bounds[1])
+			if err != nil {
+				return truthUnknown, err
+			}
+			in = in && (c < 0 || (c == 0 && n.op != OpInRange))
+		}
+		return truthOf(in == (n.op != OpNotBetween)), nil
+
+	case OpArrayOverlaps, OpArrayContainsAny, OpArrayContainsAll:
+		array := reflect.ValueOf(field)
+		if array.Kind() != reflect.Slice && array.Kind() != reflect.Array {
+			return truthUnknown, fmt.Errorf("operator %q expects an array column, %q is %T", n.op, n.columnName, field)
+		}
+
+		values := reflect.ValueOf(n.value)
+		all := n.op == OpArrayContainsAll
+		for i := 0; i < values.Len(); i++ {
+			in, err := containsValue(array, values.Index(i).Interface())
+			if err != nil {
+				return truthUnknown, err
+			}
+			if in != all {
+				return truthOf(in), nil
+			}
+		}
+		return truthOf(all), nil
+
+	case OpArrayLength:
+		array := reflect.ValueOf(field)
+		if array.Kind() != reflect.Slice && array.Kind() != reflect.Array {
+			return truthUnknown, fmt.Errorf("operator %q expects an array column, %q is %T", n.op, n.columnName, field)
+		}
+		args := n.value.([]any)
+		return truthOf(compared(args[0].(Operator), cmp.Compare(array.Len(), args[1].(int)))), nil
+
+	case OpJSONCompare, OpJSONHasKey, OpJSONContains:
+		return n.evaluateJSON(field)
+	}
+
+	return truthUnknown, fmt.Errorf("operator %q cannot be evaluated in Go", n.op)
+}
+
+// evaluateJSON evaluates the JSON operators on a column holding a JSON
+// document, as text or as the Go value it is encoded from.
+func (n *FieldCondition) evaluateJSON(field any) (truth, error) {
+	doc, err := jsonDocument(field)
+	if err != nil {
+		return truthUnknown, fmt.Errorf("operator %q on %q: %w", n.op, n.columnName, err)
+	}
+
+	if n.op == OpJSONHasKey {
+		_, found := jsonAtPath(doc, splitJSONPath(n.value.(string)))
+		return truthOf(found), nil
+	}
+
+	args := n.value.([]any)
+	value, found := jsonAtPath(doc, splitJSONPath(args[0].(string)))
+	if !found || (value == nil && n.op == OpJSONCompare) {
+		return truthUnknown, nil
+	}
+
+	if n.op == OpJSONCompare {
+		c, err := compareValues(value, args[2])
+		if err != nil {
+			return truthUnknown, err
+		}
+		return truthOf(compared(args[1].(Operator), c)), nil
+	}
+
+	want, err := jsonDocument(args[1])
+	if err != nil {
+		return truthUnknown, fmt.Errorf("operator %q: %w", n.op, err)
+	}
+	return truthOf(jsonContains(value, want)), nil
+}
+
+// jsonDocument decodes JSON text, or the JSON encoding of any other value,
+// to maps, slices and scalars.
+func jsonDocument(value any) (any, error) {
+	var data []byte
+	switch v := value.(type) {
+	case string:
+		data = []byte(v)
+	case []byte:
+		data = v
+	case json.RawMessage:
+		data = v
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+
+	var doc any
+	err := json.Unmarshal(data, &doc)
+	return doc, err
+}
+
+// jsonAtPath returns the value at a path into a decoded JSON document,
+// where numbers index arrays.
+func jsonAtPath(doc any, path []string) (any, bool) {
+	for _, key := range path {
+		switch v := doc.(type) {
+		case map[string]any:
+			var ok bool
+			if doc, ok = v[key]; !ok {
+				return nil, false
+			}
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			doc = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// jsonContains reports whether a decoded JSON document contains another,
+// as the Postgres @> operator does: objects contain the keys of want,
+// arrays its items and scalars are equal.
+func jsonContains(have, want any) bool {
+	switch w := want.(type) {
+	case map[string]any:
+		h, ok := have.(map[string]any)
+		if !ok {
+			return false
+		}
+		for key, value := range w {
+			if v, ok := h[key]; !ok || !jsonContains(v, value) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		h, ok := have.([]any)
+		if !ok {
+			return false
+		}
+		for _, item := range w {
+			if !slices.ContainsFunc(h, func(v any) bool { return jsonContains(v, item) }) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// an array contains its scalar items
+	if h, ok := have.([]any); ok {
+		return slices.ContainsFunc(h, func(v any) bool { return v == want })
+	}
+	return have == want
+}
+
+func compared(op Operator, c int) bool {
+	switch op {
+	case OpEqual:
+		return c == 0
+	case OpNotEqual:
+		return c != 0
+	case OpLessThan:
+		return c < 0
+	case OpLessThanOrEqual:
+		return c <= 0
+	case OpGreaterThan:
+		return c > 0
+	}
+	return c >= 0
+}
+
+func matchString(op Operator, s, value string) bool {
+	switch op {
+	case OpContains, OpNotContains, OpStartsWith, OpNotStartsWith, OpEndsWith, OpNotEndsWith:
+		s, value = strings.ToLower(s), strings.ToLower(value)
+	}
+
+	switch op {
+	case OpContains, OpContainsCase:
+		return strings.Contains(s, value)
+	case OpNotContains, OpNotContainsCase:
+		return !strings.Contains(s, value)
+	case OpStartsWith, OpStartsWithCase:
+		return strings.HasPrefix(s, value)
+	case OpNotStartsWith, OpNotStartsWithCase:
+		return !strings.HasPrefix(s, value)
+	case OpEndsWith, OpEndsWithCase:
+		return strings.HasSuffix(s, value)
+	}
+	return !strings.HasSuffix(s, value)
+}
+
+// containsValue reports whether the list, a slice or an array, holds an
+// element equal to value.
+func containsValue(list reflect.Value, value any) (bool, error) {
+	for i := 0; i < list.Len(); i++ {
+		c, err := compareValues(list.Index(i).Interface(), value)
+		if err != nil {
+			return false, err
+		}
+		if c == 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// columnValue reads a column of the model, following relation paths, e.g
+// "Author.name", and columns qualified by the table alias, e.g
+// "book.title". NULL values, nil pointers and slices, and relations that
+// were not loaded are returned as nil.
+func columnValue(table *schema.Table, v reflect.Value, column string) (any, error) {
+	names := strings.Split(column, ".")
+	if len(names) > 1 && names[0] == table.Alias {
+		names = names[1:]
+	}
+
+	for _, name := range names[:len(names)-1] {
+		rel := findRelation(table, name)
+		if rel == nil {
+			return nil, fmt.Errorf("%s has no relation %q", table.TypeName, name)
+		}
+
+		v = rel.Field.Value(v)
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return nil, nil
+			}
+			v = v.Elem()
+		}
+		table = rel.JoinTable
+	}
+
+	field, ok := table.FieldMap[names[len(names)-1]]
+	if !ok {
+		return nil, fmt.Errorf("%s has no column %q", table.TypeName, names[len(names)-1])
+	}
+	return fieldValue(field.Value(v))
+}
+
+// findRelation finds a relation by its Go name, e.g "Author", or by the
+// alias bun joins it with, e.g "author".
+func findRelation(table *schema.Table, name string) *schema.Relation {
+	if rel, ok := table.Relations[name]; ok {
+		return rel
+	}
+	for _, rel := range table.Relations {
+		if rel.Field.Name == name {
+			return rel
+		}
+	}
+	return nil
+}
+
+func fieldValue(v reflect.Value) (any, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.IsNil() {
+		return nil, nil
+	}
+
+	// e.g sql.NullString
+	if valuer, ok := v.Interface().(driver.Valuer); ok && v.Type() != timeType {
+		return valuer.Value()
+	}
+	return v.Interface(), nil
+}
+
+// compareValues compares two values the way the database would, e.g an
+// int with a float64 or a named string type with a string.
+func compareValues(a, b any) (int, error) {
+	switch x := comparableValue(a).(type) {
+	case int64:
+		switch y := comparableValue(b).(type) {
+		case int64:
+			return cmp.Compare(x, y), nil
+		case float64:
+			return cmp.Compare(float64(x), y), nil
+		}
+	case float64:
+		switch y := comparableValue(b).(type) {
+		case int64:
+			return cmp.Compare(x, float64(y)), nil
+		case float64:
+			return cmp.Compare(x, y), nil
+		}
+	case string:
+		if y, ok := comparableValue(b).(string); ok {
+			return strings.Compare(x, y), nil
+		}
+	case bool:
+		if y, ok := comparableValue(b).(bool); ok {
+			return cmp.Compare(boolInt(x), boolInt(y)), nil
+		}
+	case time.Time:
+		if y, ok := comparableValue(b).(time.Time); ok {
+			return x.Compare(y), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %T with %T", a, b)
+}
+
+// comparableValue reduces a value to an int64, a float64, a string, a bool
+// or a time.Time, when it is one of their kinds.
+func comparableValue(value any) any {
+	if t, ok := value.(time.Time); ok {
+		return t
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u := v.Uint(); u <= math.MaxInt64 {
+			return int64(u)
+		}
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return v.String()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes())
+		}
+	}
+	return value
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
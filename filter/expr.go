@@ -0,0 +1,577 @@
+package filter
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/uptrace/bun/dialect/pgdialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// ExprError is an invalid filter expression, at the 1-based position Pos
+// of the offending character or token.
+type ExprError struct {
+	Pos     int
+	Message string
+}
+
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Message)
+}
+
+// ParseExpr compiles a filter expression, e.g
+//
+//	status in ("a", "b") and age >= 18 and not (name ~ "bot" or name is null)
+//
+// into a condition. Comparisons put a column before its operator and value:
+//
+//	=, !=, <, <=, >, >=           Eq, NEq, Lt, Lte, Gt, Gte (== and <> work too)
+//	~, !~, ~*, !~*                Matches, NotMatches, MatchesFold, NotMatchesFold
+//	contains, starts, ends        Contains, StartsWith, EndsWith
+//	in (...), not in (...)        In, NotIn
+//	between x and y               Between, with null for an open bound
+//	not between x and y           NotBetween
+//	is null, is not null          IsNull, IsNotNull
+//
+// Other operators are written by name, e.g `name containscs "Bo"` or
+// `tags hasany ("a", "b")`. Comparisons are combined with "and", "or",
+// "not" and parentheses, "and" binding tighter than "or". Values are
+// double quoted strings with Go escapes, integers, decimals, true, false
+// and RFC 3339 times, e.g time("2024-03-10T00:00:00Z"). Keywords ignore
+// case. When allow is not nil, only its columns and operators are
+// accepted. Errors are reported as an *ExprError. An expression whose
+// comparisons are all skipped, e.g `name contains ""`, gives a nil
+// Condition.
+func ParseExpr(expr string, allow Allowlist) (Condition, error) {
+	tokens, err := lexExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{tokens: tokens, allow: allow}
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.fail(tok, "unexpected %q", tok.text)
+	}
+	if isEmpty(cond) {
+		return nil, nil
+	}
+	return cond, nil
+}
+
+type exprTokenKind int
+
+const (
+	tokEOF exprTokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokSymbol
+)
+
+type exprToken struct {
+	kind  exprTokenKind
+	text  string
+	pos   int
+	value any // of strings and numbers
+}
+
+// exprSymbols are the symbols of the language, longest first.
+var exprSymbols = []string{"!~*", "!~", "~*", "<=", ">=", "<>", "!=", "==", "~", "=", "<", ">", "(", ")", ","}
+
+func lexExpr(expr string) ([]exprToken, error) {
+	var (
+		runes  = []rune(expr)
+		tokens []exprToken
+	)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+
+		case r == '"':
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(runes) {
+				return nil, &ExprError{Pos: start + 1, Message: "unterminated string"}
+			}
+			i++
+
+			raw := string(runes[start:i])
+			s, err := strconv.Unquote(raw)
+			if err != nil {
+				return nil, &ExprError{Pos: start + 1, Message: fmt.Sprintf("invalid string %s", raw)}
+			}
+			tokens = append(tokens, exprToken{kind: tokString, text: raw, pos: start + 1, value: s})
+			continue
+
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			for i++; i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == 'e' || runes[i] == 'E' ||
+				((runes[i] == '+' || runes[i] == '-') && (runes[i-1] == 'e' || runes[i-1] == 'E'))); i++ {
+			}
+
+			raw := string(runes[start:i])
+			var value any
+			if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+				value = n
+			} else if f, err := strconv.ParseFloat(raw, 64); err == nil {
+				value = f
+			} else {
+				return nil, &ExprError{Pos: start + 1, Message: fmt.Sprintf("invalid number %s", raw)}
+			}
+			tokens = append(tokens, exprToken{kind: tokNumber, text: raw, pos: start + 1, value: value})
+			continue
+
+		case unicode.IsLetter(r) || r == '_':
+			for i++; i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.'); i++ {
+			}
+			tokens = append(tokens, exprToken{kind: tokIdent, text: string(runes[start:i]), pos: start + 1})
+			continue
+		}
+
+		symbol := ""
+		for _, s := range exprSymbols {
+			if strings.HasPrefix(string(runes[i:]), s) {
+				symbol = s
+				break
+			}
+		}
+		if symbol == "" {
+			return nil, &ExprError{Pos: start + 1, Message: fmt.Sprintf("unexpected character %q", r)}
+		}
+		i += len([]rune(symbol))
+		tokens = append(tokens, exprToken{kind: tokSymbol, text: symbol, pos: start + 1})
+	}
+
+	return append(tokens, exprToken{kind: tokEOF, text: "end of expression", pos: len(runes) + 1}), nil
+}
+
+// exprComparisons maps the symbols of comparisons to their operator.
+var exprComparisons = map[string]Operator{
+	"=":   OpEqual,
+	"==":  OpEqual,
+	"!=":  OpNotEqual,
+	"<>":  OpNotEqual,
+	"<":   OpLessThan,
+	"<=":  OpLessThanOrEqual,
+	">":   OpGreaterThan,
+	">=":  OpGreaterThanOrEqual,
+	"~":   OpMatches,
+	"!~":  OpNotMatches,
+	"~*":  OpMatchesFold,
+	"!~*": OpNotMatchesFold,
+}
+
+// exprKeywords cannot be used as column names.
+var exprKeywords = map[string]bool{
+	"and": true, "or": true, "not": true, "in": true, "is": true, "null": true, "between": true,
+	"contains": true, "starts": true, "ends": true, "true": true, "false": true,
+}
+
+type exprParser struct {
+	tokens []exprToken
+	i      int
+	allow  Allowlist
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.i]
+}
+
+func (p *exprParser) next() exprToken {
+	tok := p.tokens[p.i]
+	if tok.kind != tokEOF {
+		p.i++
+	}
+	return tok
+}
+
+func (p *exprParser) fail(tok exprToken, format string, args ...any) error {
+	return &ExprError{Pos: tok.pos, Message: fmt.Sprintf(format, args...)}
+}
+
+func isKeyword(tok exprToken, keyword string) bool {
+	return tok.kind == tokIdent && strings.EqualFold(tok.text, keyword)
+}
+
+func isSymbol(tok exprToken, symbol string) bool {
+	return tok.kind == tokSymbol && tok.text == symbol
+}
+
+func (p *exprParser) expectSymbol(symbol string) error {
+	if tok := p.next(); !isSymbol(tok, symbol) {
+		return p.fail(tok, "expected %q, got %q", symbol, tok.text)
+	}
+	return nil
+}
+
+func (p *exprParser) parseOr() (Condition, error) {
+	return p.parseJoined("or", p.parseAnd, Or)
+}
+
+func (p *exprParser) parseAnd() (Condition, error) {
+	return p.parseJoined("and", p.parseUnary, And)
+}
+
+func (p *exprParser) parseJoined(keyword string, parse func() (Condition, error), group func(...Condition) *Group) (Condition, error) {
+	cond, err := parse()
+	if err != nil {
+		return nil, err
+	}
+
+	conds := []Condition{cond}
+	for isKeyword(p.peek(), keyword) {
+		p.next()
+		if cond, err = parse(); err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+	}
+
+	if len(conds) == 1 {
+		return conds[0], nil
+	}
+	return group(conds...), nil
+}
+
+func (p *exprParser) parseUnary() (Condition, error) {
+	if isKeyword(p.peek(), "not") {
+		p.next()
+		cond, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return Not(cond), nil
+	}
+
+	if isSymbol(p.peek(), "(") {
+		p.next()
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return cond, p.expectSymbol(")")
+	}
+
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (Condition, error) {
+	column := p.next()
+	if column.kind != tokIdent || exprKeywords[strings.ToLower(column.text)] {
+		return nil, p.fail(column, "expected a column, got %q", column.text)
+	}
+
+	opTok := p.next()
+	op, err := p.parseOperator(opTok)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.allow != nil {
+		allowed, ok := p.allow[column.text]
+		if !ok {
+			return nil, p.fail(column, "cannot filter on %q", column.text)
+		}
+		if !containsOperator(allowed, op) {
+			return nil, p.fail(opTok, "operator %q is not allowed on %q", opTok.text, column.text)
+		}
+	}
+
+	valueTok := p.peek()
+	var value any
+	switch op {
+	case OpIsNull:
+		value, err = p.parseIsNull()
+	case OpIn, OpNotIn, OpArrayOverlaps, OpArrayContainsAny, OpArrayContainsAll,
+		OpArrayLength, OpJSONCompare, OpJSONContains:
+		value, err = p.parseList()
+	case OpBetween, OpNotBetween, OpInRange:
+		value, err = p.parseBetween()
+	default:
+		value, err = p.parseValue()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cond, err := NewCondition(op, column.text, value)
+	if err != nil {
+		return nil, p.fail(valueTok, "%s", err)
+	}
+	return cond, nil
+}
+
+func (p *exprParser) parseOperator(tok exprToken) (Operator, error) {
+	if tok.kind == tokSymbol {
+		if op, ok := exprComparisons[tok.text]; ok {
+			return op, nil
+		}
+	}
+
+	switch {
+	case isKeyword(tok, "is"):
+		return OpIsNull, nil
+	case isKeyword(tok, "not") && isKeyword(p.peek(), "in"):
+		p.next()
+		return OpNotIn, nil
+	case isKeyword(tok, "not") && isKeyword(p.peek(), "between"):
+		p.next()
+		return OpNotBetween, nil
+	case tok.kind == tokIdent:
+		// any other operator by its name, e.g contains or hasany
+		if op := Operator(strings.ToLower(tok.text)); op.IsValid() && op != OpIsNull {
+			return op, nil
+		}
+	}
+	return "", p.fail(tok, "expected an operator, got %q", tok.text)
+}
+
+// parseIsNull parses what follows "is": "null" or "not null".
+func (p *exprParser) parseIsNull() (bool, error) {
+	isNull := true
+	if isKeyword(p.peek(), "not") {
+		p.next()
+		isNull = false
+	}
+	if tok := p.next(); !isKeyword(tok, "null") {
+		return false, p.fail(tok, "expected null, got %q", tok.text)
+	}
+	return isNull, nil
+}
+
+func (p *exprParser) parseList() ([]any, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+
+	var list []any
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+
+		if tok := p.next(); isSymbol(tok, ")") {
+			return list, nil
+		} else if !isSymbol(tok, ",") {
+			return nil, p.fail(tok, `expected "," or ")", got %q`, tok.text)
+		}
+	}
+}
+
+// parseBetween parses the bounds "x and y" of a range, either of which
+// may be null to leave the range open on that side.
+func (p *exprParser) parseBetween() ([]any, error) {
+	bounds := make([]any, 2)
+	for i := range bounds {
+		if i > 0 {
+			if tok := p.next(); !isKeyword(tok, "and") {
+				return nil, p.fail(tok, "expected and, got %q", tok.text)
+			}
+		}
+		if isKeyword(p.peek(), "null") {
+			p.next()
+			continue
+		}
+
+		bound, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		bounds[i] = bound
+	}
+	return bounds, nil
+}
+
+func (p *exprParser) parseValue() (any, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == tokString, tok.kind == tokNumber:
+		return tok.value, nil
+	case isKeyword(tok, "true"):
+		return true, nil
+	case isKeyword(tok, "false"):
+		return false, nil
+	case isKeyword(tok, "time"):
+		return p.parseTime()
+	case isKeyword(tok, "null"):
+		return nil, p.fail(tok, "compare with null using is null or is not null")
+	}
+	return nil, p.fail(tok, "expected a value, got %q", tok.text)
+}
+
+// parseTime parses the rest of a time literal, `time("2024-03-10T00:00:00Z")`.
+func (p *exprParser) parseTime() (any, error) {
+	if err := p.expectSymbol("("); err != nil {
+		return nil, err
+	}
+	tok := p.next()
+	if tok.kind != tokString {
+		return nil, p.fail(tok, "expected a quoted time, got %q", tok.text)
+	}
+	t, err := time.Parse(time.RFC3339Nano, tok.value.(string))
+	if err != nil {
+		return nil, p.fail(tok, "invalid time %s, expected RFC 3339", tok.text)
+	}
+	if err := p.expectSymbol(")"); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// exprOperators maps operators to how String writes them, other operators
+// being written by name.
+var exprOperators = map[Operator]string{
+	OpEqual:              "=",
+	OpNotEqual:           "!=",
+	OpLessThan:           "<",
+	OpLessThanOrEqual:    "<=",
+	OpGreaterThan:        ">",
+	OpGreaterThanOrEqual: ">=",
+	OpMatches:            "~",
+	OpNotMatches:         "!~",
+	OpMatchesFold:        "~*",
+	OpNotMatchesFold:     "!~*",
+	OpNotIn:              "not in",
+	OpNotBetween:         "not between",
+}
+
+// String formats the condition the way ParseExpr reads it, e.g
+// `status in ("a", "b")` or `attrs json ("size.width", "gt", 20)`.
+func (n *FieldCondition) String() string {
+	if n == nil {
+		return ""
+	}
+	if n.op == "" {
+		return conditionSQL(n)
+	}
+
+	op, ok := exprOperators[n.op]
+	if !ok {
+		op = string(n.op)
+	}
+
+	switch n.op {
+	case OpIsNull:
+		if n.value == true {
+			return n.columnName + " is null"
+		}
+		return n.columnName + " is not null"
+	case OpIn, OpNotIn, OpArrayOverlaps, OpArrayContainsAny, OpArrayContainsAll,
+		OpArrayLength, OpJSONCompare, OpJSONContains:
+		v := reflect.ValueOf(n.value)
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = formatExprValue(v.Index(i).Interface())
+		}
+		return fmt.Sprintf("%s %s (%s)", n.columnName, op, strings.Join(items, ", "))
+	case OpBetween, OpNotBetween, OpInRange:
+		bounds := n.value.([]any)
+		return fmt.Sprintf("%s %s %s and %s", n.columnName, op, formatExprValue(bounds[0]), formatExprValue(bounds[1]))
+	}
+	return fmt.Sprintf("%s %s %s", n.columnName, op, formatExprValue(n.value))
+}
+
+// String formats the group the way ParseExpr reads it, e.g
+// `age >= 18 and (name is null or name contains "bo")`.
+func (g *Group) String() string {
+	if g.isEmpty() {
+		return ""
+	}
+
+	sep := " and "
+	if g.or {
+		sep = " or "
+	}
+
+	items := make([]string, len(g.conditions))
+	for i, cond := range g.conditions {
+		items[i] = formatNested(cond)
+	}
+	return strings.Join(items, sep)
+}
+
+// String formats the negation the way ParseExpr reads it, e.g
+// `not name contains "bot"`.
+func (n *Negation) String() string {
+	if n.isEmpty() {
+		return ""
+	}
+	return "not " + formatNested(n.condition)
+}
+
+// formatNested formats a condition within a group or a negation, in
+// parentheses when it is a group itself.
+func formatNested(cond Condition) string {
+	if g, ok := cond.(*Group); ok && len(g.conditions) > 1 {
+		return "(" + g.String() + ")"
+	}
+	return formatCondition(cond)
+}
+
+func formatCondition(cond Condition) string {
+	if s, ok := cond.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return conditionSQL(cond)
+}
+
+func conditionSQL(cond Condition) string {
+	b, err := cond.AppendQuery(schema.NewFormatter(pgdialect.New()), nil)
+	if err != nil {
+		return fmt.Sprintf("%%!(%s)", err)
+	}
+	return string(b)
+}
+
+func formatExprValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return "time(" + strconv.Quote(v.Format(time.RFC3339Nano)) + ")"
+	case fmt.Stringer:
+		return strconv.Quote(v.String())
+	}
+
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		// keep a decimal point, so the value reads back as a float
+		s := strconv.FormatFloat(v.Float(), 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eIN") {
+			s += ".0"
+		}
+		return s
+	}
+	return strconv.Quote(fmt.Sprint(value))
+}
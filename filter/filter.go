@@ -11,20 +11,18 @@ func Limit(q *bun.SelectQuery, limit int) {
 	q.Limit(limit)
 }
 
+// OrderBy orders the query by column. It panics on a direction other than
+// "asc" or "desc": use a Builder, or ParseDirection, for user input.
 func OrderBy(q *bun.SelectQuery, column, direction string) {
 	if strings.TrimSpace(direction) == "" {
 		return
 	}
-	value := strings.ToLower(strings.TrimSpace(direction))
 
-	switch value {
-	case "asc":
-		q.OrderExpr(column + " asc")
-	case "desc":
-		q.OrderExpr(column + " desc")
-	default:
-		panic("invalid order direction: should be 'asc' or 'desc'")
+	value, err := ParseDirection(direction)
+	if err != nil {
+		panic(err.Error())
 	}
+	q.OrderExpr("? "+value, bun.Ident(column))
 }
 
 func OrderByAsc(q *bun.SelectQuery, column string) {
@@ -39,84 +37,102 @@ type allBunQueryType interface {
 	*bun.SelectQuery | *bun.UpdateQuery | *bun.DeleteQuery
 }
 
-func Where[T allBunQueryType](bunQ T, opt *sqlWhere) {
-	if opt == nil {
+// Where adds the condition to the query, joined with "AND".
+//
+// Columns on a relation path of the query's model, e.g "Author.name" on a
+// Book query, join the relation, which must be has-one or belongs-to, and
+// refer to its alias, e.g "author.name". Calling q.Relation("Author", apply)
+// afterwards keeps the join but replaces its apply function. An unknown
+// relation path is recorded as the query's error, returned when it runs,
+// and the condition is skipped: use a Builder to collect such errors from
+// user input.
+func Where[T allBunQueryType](bunQ T, cond Condition) {
+	if isEmpty(cond) {
 		return
 	}
-
-	var (
-		sqlQuery    = opt.stmt
-		column      = bun.Ident(opt.columnName)
-		columnValue = opt.columnValue
-		isNullQuery = opt.isANullQueryType()
-	)
-
-	if !isNullQuery {
-		switch q := any(bunQ).(type) {
-		case *bun.SelectQuery:
-			q.Where(sqlQuery, column, columnValue)
-		case *bun.UpdateQuery:
-			q.Where(sqlQuery, column, columnValue)
-		case *bun.DeleteQuery:
-			q.Where(sqlQuery, column, columnValue)
-		default:
-			fmt.Println("unsupported type: and where only works with Select, Update & Delete Query")
-			panic("unsupported type: and where only works with Select, Update & Delete Query")
-		}
-	}
-
-	if isNullQuery {
-		switch q := any(bunQ).(type) {
-		case *bun.SelectQuery:
-			q.Where(sqlQuery, column)
-		case *bun.UpdateQuery:
-			q.Where(sqlQuery, column)
-		case *bun.DeleteQuery:
-			q.Where(sqlQuery, column)
-		default:
-			fmt.Println("unsupported type: and where only works with Select, Update & Delete Query")
-			panic("unsupported type: and where only works with Select, Update & Delete Query")
-		}
+	cond, err := joinRelations(bunQ, queryTable(bunQ), cond, nil)
+	if err != nil {
+		setErr(bunQ, fmt.Errorf("filter: %w", err))
+		return
 	}
+	cond.applyTo(newWhereQuery(bunQ), false)
 }
 
-func OrWhere[T allBunQueryType](bunQ T, opt *sqlWhere) {
-	if opt == nil {
+// OrWhere adds the condition to the query, joined with "OR". Relation
+// columns are handled as with Where.
+func OrWhere[T allBunQueryType](bunQ T, cond Condition) {
+	if isEmpty(cond) {
 		return
 	}
+	cond, err := joinRelations(bunQ, queryTable(bunQ), cond, nil)
+	if err != nil {
+		setErr(bunQ, fmt.Errorf("filter: %w", err))
+		return
+	}
+	cond.applyTo(newWhereQuery(bunQ), true)
+}
 
-	var (
-		sqlQuery    = opt.stmt
-		column      = bun.Ident(opt.columnName)
-		columnValue = opt.columnValue
-		isNullQuery = opt.isANullQueryType()
-	)
-
-	if !isNullQuery {
-		switch q := any(bunQ).(type) {
-		case *bun.SelectQuery:
-			q.WhereOr(sqlQuery, column, columnValue)
-		case *bun.UpdateQuery:
-			q.WhereOr(sqlQuery, column, columnValue)
-		case *bun.DeleteQuery:
-			q.WhereOr(sqlQuery, column, columnValue)
-		default:
-			fmt.Println("unsupported type: or where only works with Select, Update & Delete Query")
-			panic("unsupported type: or where only works with Select, Update & Delete Query")
-		}
+// whereQuery is the where clause shared by select, update and delete queries.
+type whereQuery interface {
+	where(query string, args ...any)
+	whereOr(query string, args ...any)
+	whereGroup(sep string, fn func(q whereQuery))
+}
+
+func newWhereQuery[T allBunQueryType](bunQ T) whereQuery {
+	switch q := any(bunQ).(type) {
+	case *bun.SelectQuery:
+		return selectWhere{q}
+	case *bun.UpdateQuery:
+		return updateWhere{q}
+	case *bun.DeleteQuery:
+		return deleteWhere{q}
+	default:
+		panic("unsupported type: where only works with Select, Update & Delete Query")
 	}
+}
 
-	if isNullQuery {
-		switch q := any(bunQ).(type) {
-		case *bun.SelectQuery:
-			q.WhereOr(sqlQuery, column)
-		case *bun.UpdateQuery:
-			q.WhereOr(sqlQuery, column)
-		case *bun.DeleteQuery:
-			q.WhereOr(sqlQuery, column)
-		default:
-			fmt.Println("unsupported type: or where only works with Select, Update & Delete Query")
-			panic("unsupported type: or where only works with Select, Update & Delete Query")
-		}
+// setErr records err on the query, which fails with it when it runs.
+func setErr[T allBunQueryType](bunQ T, err error) {
+	switch q := any(bunQ).(type) {
+	case *bun.SelectQuery:
+		q.Err(err)
+	case *bun.UpdateQuery:
+		q.Err(err)
+	case *bun.DeleteQuery:
+		q.Err(err)
 	}
 }
+
+type selectWhere struct{ q *bun.SelectQuery }
+
+func (w selectWhere) where(query string, args ...any)   { w.q.Where(query, args...) }
+func (w selectWhere) whereOr(query string, args ...any) { w.q.WhereOr(query, args...) }
+func (w selectWhere) whereGroup(sep string, fn func(q whereQuery)) {
+	w.q.WhereGroup(sep, func(q *bun.SelectQuery) *bun.SelectQuery {
+		fn(selectWhere{q})
+		return q
+	})
+}
+
+type updateWhere struct{ q *bun.UpdateQuery }
+
+func (w updateWhere) where(query string, args ...any)   { w.q.Where(query, args...) }
+func (w updateWhere) whereOr(query string, args ...any) { w.q.WhereOr(query, args...) }
+func (w updateWhere) whereGroup(sep string, fn func(q whereQuery)) {
+	w.q.WhereGroup(sep, func(q *bun.UpdateQuery) *bun.UpdateQuery {
+		fn(updateWhere{q})
+		return q
+	})
+}
+
+type deleteWhere struct{ q *bun.DeleteQuery }
+
+func (w deleteWhere) where(query string, args ...any)   { w.q.Where(query, args...) }
+func (w deleteWhere) whereOr(query string, args ...any) { w.q.WhereOr(query, args...) }
+func (w deleteWhere) whereGroup(sep string, fn func(q whereQuery)) {
+	w.q.WhereGroup(sep, func(q *bun.DeleteQuery) *bun.DeleteQuery {
+		fn(deleteWhere{q})
+		return q
+	})
+}
@@ -4,13 +4,21 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	dbstore "github.com/otyang/go-dbstore"
+	"github.com/otyang/go-dbstore/obun"
+	"github.com/otyang/go-dbstore/seeder"
 	"github.com/stretchr/testify/assert"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/dialect/sqlitedialect"
 	"github.com/uptrace/bun/driver/sqliteshim"
 	"github.com/uptrace/bun/extra/bundebug"
+	"github.com/uptrace/bun/schema"
 )
 
 type User struct {
@@ -105,3 +113,1716 @@ func TestALL(t *testing.T) {
 
 	t.Log(users)
 }
+
+func TestGroups(t *testing.T) {
+	var (
+		ctx = context.Background()
+		db  = newDB(t)
+		err = resetDB(ctx, db)
+	)
+	assert.NoError(t, err)
+
+	t.Run("nested and, or & not on select", func(t *testing.T) {
+		var users []User
+		q := db.NewSelect().Model(&users).Order("id")
+		Where(q, And(
+			Or(Eq("name", "google_1"), Eq("name", "google_2"), Eq("name", "google_3")),
+			Not(Or(Eq("id", "_id_1"), Eq("id", "_id_3"))),
+		))
+
+		err := q.Scan(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(users))
+		assert.Equal(t, "_id_2", users[0].Id)
+	})
+
+	t.Run("not as the only condition", func(t *testing.T) {
+		var users []User
+		q := db.NewSelect().Model(&users)
+		Where(q, Not(Or(Eq("id", "_id_1"), Eq("id", "_id_2"))))
+
+		err := q.Scan(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(users))
+	})
+
+	t.Run("skipped conditions are left out", func(t *testing.T) {
+		var users []User
+		q := db.NewSelect().Model(&users)
+		Where(q, And(Eq("id", nil), Or(Contains("name", ""))))
+		Where(q, Not(Eq("id", nil)))
+		OrWhere(q, Or())
+
+		assert.NotContains(t, q.String(), "WHERE")
+		err := q.Scan(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, len(users))
+	})
+
+	t.Run("or where a group", func(t *testing.T) {
+		var users []User
+		q := db.NewSelect().Model(&users)
+		Where(q, Eq("id", "_id_1"))
+		OrWhere(q, And(Eq("id", "_id_4"), Eq("name", "google_4")))
+
+		err := q.Scan(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(users))
+	})
+
+	t.Run("update & delete queries", func(t *testing.T) {
+		uq := db.NewUpdate().Model((*User)(nil)).Set("phone = ?", "updated")
+		Where(uq, Or(Eq("id", "_id_1"), Eq("id", "_id_2")))
+		res, err := uq.Exec(ctx)
+		assert.NoError(t, err)
+		n, _ := res.RowsAffected()
+		assert.Equal(t, int64(2), n)
+
+		dq := db.NewDelete().Model((*User)(nil))
+		Where(dq, And(Eq("phone", "updated"), Not(Eq("id", "_id_1"))))
+		res, err = dq.Exec(ctx)
+		assert.NoError(t, err)
+		n, _ = res.RowsAffected()
+		assert.Equal(t, int64(1), n)
+	})
+}
+
+func TestQueryParser(t *testing.T) {
+	type Person struct {
+		Id      int64 `bun:",pk"`
+		Name    string
+		Age     int
+		BornAt  time.Time
+		Retired bool
+	}
+
+	var (
+		ctx = context.Background()
+		db  = newDB(t)
+		err = resetDB(ctx, db)
+	)
+	assert.NoError(t, err)
+
+	t.Run("parse and apply", func(t *testing.T) {
+		p, err := NewQueryParser(db, (*User)(nil), Allowlist{
+			"id":   {OpEqual, OpIn},
+			"name": {OpContains},
+		}, WithMaxLimit(2))
+		assert.NoError(t, err)
+
+		values, _ := url.ParseQuery("name[contains]=goo&id[in]=_id_1,_id_2,_id_3&sort=-id&limit=50&page=3")
+		pq, err := p.Parse(values)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(pq.Conditions))
+		assert.Equal(t, SortSpec{{Column: "id", Desc: true}}, pq.Sort)
+		assert.Equal(t, 2, pq.Limit)
+
+		var users []User
+		q := db.NewSelect().Model(&users)
+		pq.Apply(q)
+
+		err = q.Scan(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(users))
+		assert.Equal(t, "_id_3", users[0].Id)
+
+		for _, query := range []string{"limit=0", "name[contains]=goo"} {
+			values, _ := url.ParseQuery(query)
+			pq, err := p.Parse(values)
+			assert.NoError(t, err)
+			assert.Equal(t, 2, pq.Limit, query)
+		}
+	})
+
+	t.Run("coerces values to the field types", func(t *testing.T) {
+		p, err := NewQueryParser(db, (*Person)(nil), Allowlist{
+			"age":     {OpGreaterThanOrEqual},
+			"born_at": {OpLessThan},
+			"retired": {OpEqual, OpIsNull},
+		})
+		assert.NoError(t, err)
+
+		values, _ := url.ParseQuery("age[gte]=18&born_at[lt]=2000-01-02&retired=true")
+		pq, err := p.Parse(values)
+		assert.NoError(t, err)
+
+		q := db.NewSelect().Model((*Person)(nil))
+		pq.Apply(q)
+		assert.Contains(t, q.String(), `("age" >= 18)`)
+		assert.Contains(t, q.String(), `("born_at" < '2000-01-02 00:00:00+00:00')`)
+		assert.Contains(t, q.String(), `("retired" = TRUE)`)
+	})
+
+	t.Run("returns validation errors", func(t *testing.T) {
+		p, err := NewQueryParser(db, (*Person)(nil), Allowlist{"age": {OpGreaterThanOrEqual}})
+		assert.NoError(t, err)
+
+		values, _ := url.ParseQuery("age[gte]=old&age[lt]=5&name[contains]=bo&age[eq=1&sort=name&limit=-1")
+		_, err = p.Parse(values)
+
+		var errs ValidationErrors
+		assert.ErrorAs(t, err, &errs)
+		assert.Equal(t, 6, len(errs))
+		assert.Equal(t, "age[gte]", errs[1].Param)
+	})
+
+	t.Run("rejects unknown allowlisted columns", func(t *testing.T) {
+		_, err := NewQueryParser(db, (*Person)(nil), Allowlist{"email": {OpEqual}})
+		assert.Error(t, err)
+	})
+}
+
+func TestJSON(t *testing.T) {
+	var (
+		ctx = context.Background()
+		db  = newDB(t)
+		err = resetDB(ctx, db)
+	)
+	assert.NoError(t, err)
+
+	t.Run("decode, apply and encode back", func(t *testing.T) {
+		data := `{"and": [
+			{"or": [
+				{"field": "name", "op": "contains", "value": "google_1"},
+				{"field": "id", "op": "in", "value": ["_id_2", "_id_3"]}
+			]},
+			{"not": {"field": "phone", "op": "eq", "value": "123456789_3"}},
+			{"field": "email", "op": "null", "value": false}
+		]}`
+
+		cond, err := DecodeJSON([]byte(data), nil)
+		assert.NoError(t, err)
+
+		var users []User
+		q := db.NewSelect().Model(&users).Order("id")
+		Where(q, cond)
+		err = q.Scan(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(users))
+		assert.Equal(t, "_id_2", users[1].Id)
+
+		encoded, err := EncodeJSON(cond)
+		assert.NoError(t, err)
+		assert.JSONEq(t, data, string(encoded))
+	})
+
+	t.Run("numbers keep their form", func(t *testing.T) {
+		data := `{"or": [{"field": "age", "op": "gte", "value": 18}, {"field": "score", "op": "lt", "value": 2.5}]}`
+
+		cond, err := DecodeJSON([]byte(data), nil)
+		assert.NoError(t, err)
+
+		encoded, err := EncodeJSON(cond)
+		assert.NoError(t, err)
+		assert.JSONEq(t, data, string(encoded))
+	})
+
+	t.Run("encodes conditions built in Go", func(t *testing.T) {
+		encoded, err := EncodeJSON(Or(Gte("age", 18), Not(IsNull("email"))))
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"or": [
+			{"field": "age", "op": "gte", "value": 18},
+			{"not": {"field": "email", "op": "null", "value": true}}
+		]}`, string(encoded))
+	})
+
+	t.Run("validation errors carry the path of the node", func(t *testing.T) {
+		allow := Allowlist{"age": {OpGreaterThanOrEqual}, "name": {OpContains}}
+
+		tests := map[string]string{
+			`{"and": [{"field": "age", "op": "gte", "value": 1}, {"not": {"field": "age", "op": "lt", "value": 1}}]}`: "and[1].not.op",
+			`{"or": [{"field": "email", "op": "eq", "value": "x"}]}`:                                                  "or[0].field",
+			`{"field": "name", "op": "contains", "value": 5}`:                                                         "value",
+			`{"field": "name", "op": "like", "value": "x"}`:                                                           "op",
+			`{"field": "age", "op": "gte", "value": 1, "extra": true}`:                                                "extra",
+			`{"xor": []}`: "$",
+			`[]`:          "$",
+		}
+		for data, param := range tests {
+			_, err := DecodeJSON([]byte(data), allow)
+
+			var fieldErr *FieldError
+			if assert.ErrorAs(t, err, &fieldErr, data) {
+				assert.Equal(t, param, fieldErr.Param, data)
+			}
+		}
+	})
+}
+
+func TestFromStruct(t *testing.T) {
+	type Paging struct {
+		Ids []string `filter:"id,op=in"`
+	}
+
+	type UserFilter struct {
+		Paging
+		Name      *string `filter:"name,op=contains"`
+		Email     string  `filter:"email"`
+		NoPhone   *bool   `filter:"phone,op=null"`
+		Unrelated string
+		Ignored   string `filter:"-"`
+	}
+
+	var (
+		ctx = context.Background()
+		db  = newDB(t)
+		err = resetDB(ctx, db)
+	)
+	assert.NoError(t, err)
+
+	t.Run("nil and zero fields are skipped", func(t *testing.T) {
+		cond, err := FromStruct(UserFilter{Unrelated: "x", Ignored: "y"})
+		assert.NoError(t, err)
+		assert.True(t, isEmpty(cond))
+	})
+
+	t.Run("set fields become criteria", func(t *testing.T) {
+		var (
+			name    = "google"
+			noPhone = false
+		)
+
+		sc, err := StructCriteria(&UserFilter{
+			Paging:  Paging{Ids: []string{"_id_2", "_id_3"}},
+			Name:    &name,
+			NoPhone: &noPhone,
+		})
+		assert.NoError(t, err)
+
+		var users []User
+		err = sc(db.NewSelect().Model(&users)).Scan(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(users))
+	})
+
+	t.Run("invalid tags", func(t *testing.T) {
+		_, err := FromStruct(struct {
+			Name string `filter:"name,op=like"`
+		}{Name: "x"})
+		assert.Error(t, err)
+
+		_, err = FromStruct(struct {
+			Name int `filter:"name,op=contains"`
+		}{Name: 1})
+		assert.Error(t, err)
+
+		_, err = FromStruct("not a struct")
+		assert.Error(t, err)
+	})
+}
+
+func TestBuilder(t *testing.T) {
+	var (
+		ctx = context.Background()
+		db  = newDB(t)
+		err = resetDB(ctx, db)
+	)
+	assert.NoError(t, err)
+
+	t.Run("valid filters are applied", func(t *testing.T) {
+		var users []User
+		b := NewBuilder(db.NewSelect().Model(&users)).
+			Where(Or(Eq("id", "_id_1"), Eq("user.id", "_id_2"))).
+			OrderBy("id", " DESC ").
+			Limit(1)
+		assert.NoError(t, b.Err())
+
+		err := b.Query().Scan(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(users))
+		assert.Equal(t, "_id_2", users[0].Id)
+	})
+
+	t.Run("invalid filters are collected", func(t *testing.T) {
+		b := NewBuilder(db.NewSelect().Model((*User)(nil))).
+			Where(And(Eq("id", "_id_1"), Not(Eq("password", "x")))).
+			OrWhere(Eq("other.id", 1)).
+			OrderBy("id", "sideways").
+			OrderBy("age", "asc").
+			Limit(-1)
+
+		var errs ValidationErrors
+		assert.ErrorAs(t, b.Err(), &errs)
+		assert.Equal(t, 5, len(errs))
+		assert.Equal(t, "password", errs[0].Param)
+		assert.NotContains(t, b.Query().String(), "WHERE")
+	})
+
+	t.Run("update & delete queries", func(t *testing.T) {
+		b := NewBuilder(db.NewDelete().Model((*User)(nil))).Where(Eq("nope", 1)).OrderBy("id", "asc")
+		assert.Error(t, b.Err())
+
+		ub := NewBuilder(db.NewUpdate().Model((*User)(nil)).Set("name = ?", "x")).Where(Eq("id", "_id_9"))
+		assert.NoError(t, ub.Err())
+	})
+
+	t.Run("ParseDirection", func(t *testing.T) {
+		direction, err := ParseDirection("ASC")
+		assert.NoError(t, err)
+		assert.Equal(t, "asc", direction)
+
+		_, err = ParseDirection("up")
+		assert.ErrorIs(t, err, ErrInvalidDirection)
+	})
+}
+
+func TestSortSpec(t *testing.T) {
+	type Post struct {
+		Id          int64 `bun:",pk"`
+		Title       string
+		PublishedAt *time.Time
+	}
+
+	var (
+		ctx  = context.Background()
+		db   = newDB(t)
+		day  = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		next = day.AddDate(0, 0, 1)
+	)
+	err := db.ResetModel(ctx, (*Post)(nil))
+	assert.NoError(t, err)
+	defer db.NewDropTable().Model((*Post)(nil)).Exec(ctx)
+
+	posts := []Post{
+		{Id: 1, Title: "b", PublishedAt: &day},
+		{Id: 2, Title: "a"},
+		{Id: 3, Title: "a", PublishedAt: &next},
+		{Id: 4, Title: "c"},
+	}
+	_, err = db.NewInsert().Model(&posts).Exec(ctx)
+	assert.NoError(t, err)
+
+	ids := func(spec SortSpec) []int64 {
+		var got []Post
+		q := db.NewSelect().Model(&got)
+		spec.Apply(q)
+		assert.NoError(t, q.Scan(ctx))
+
+		ids := make([]int64, len(got))
+		for i := range got {
+			ids[i] = got[i].Id
+		}
+		return ids
+	}
+
+	t.Run("parse", func(t *testing.T) {
+		spec, err := ParseSort(" -published_at:nullslast, title ,", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, SortSpec{
+			{Column: "published_at", Desc: true, Nulls: NullsLast},
+			{Column: "title"},
+		}, spec)
+		assert.Equal(t, "-published_at:nullslast,title", spec.String())
+
+		_, err = ParseSort("title:nullsmiddle", nil)
+		assert.Error(t, err)
+
+		_, err = ParseSort("-id,password", []string{"id", "title"})
+		assert.Error(t, err)
+
+		spec, err = ParseSort("+title", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, SortSpec{{Column: "title"}}, spec)
+
+		for _, s := range []string{"--x,+y", "+-x", "-+x", "++x"} {
+			_, err = ParseSort(s, nil)
+			var fe *FieldError
+			assert.ErrorAs(t, err, &fe, s)
+		}
+	})
+
+	t.Run("multiple keys", func(t *testing.T) {
+		spec, _ := ParseSort("title,-id", nil)
+		assert.Equal(t, []int64{3, 2, 1, 4}, ids(spec))
+	})
+
+	t.Run("nulls placement is emulated on sqlite", func(t *testing.T) {
+		spec, _ := ParseSort("-published_at:nullslast,id", nil)
+		assert.Equal(t, []int64{3, 1, 2, 4}, ids(spec))
+
+		spec, _ = ParseSort("published_at:nullsfirst,-id", nil)
+		assert.Equal(t, []int64{4, 2, 1, 3}, ids(spec))
+	})
+
+	t.Run("identifiers are quoted", func(t *testing.T) {
+		q := db.NewSelect().Model((*Post)(nil))
+		SortSpec{{Column: "post.title; DROP TABLE posts", Desc: true}}.Apply(q)
+		assert.Contains(t, q.String(), `ORDER BY "post"."title; DROP TABLE posts" DESC`)
+	})
+}
+
+func TestLike(t *testing.T) {
+	type Coupon struct {
+		Id   int64 `bun:",pk"`
+		Code string
+	}
+
+	ctx := context.Background()
+	db := newDB(t)
+	err := db.ResetModel(ctx, (*Coupon)(nil))
+	assert.NoError(t, err)
+	defer db.NewDropTable().Model((*Coupon)(nil)).Exec(ctx)
+
+	coupons := []Coupon{
+		{Id: 1, Code: "50% OFF"},
+		{Id: 2, Code: "500 off"},
+		{Id: 3, Code: "Save_Now"},
+		{Id: 4, Code: "SaveXNow"},
+		{Id: 5, Code: `C:\deal*`},
+	}
+	_, err = db.NewInsert().Model(&coupons).Exec(ctx)
+	assert.NoError(t, err)
+
+	ids := func(cond Condition) []int64 {
+		var got []Coupon
+		q := db.NewSelect().Model(&got).Order("id")
+		Where(q, cond)
+		assert.NoError(t, q.Scan(ctx))
+
+		ids := make([]int64, len(got))
+		for i := range got {
+			ids[i] = got[i].Id
+		}
+		return ids
+	}
+
+	t.Run("wildcards are matched literally", func(t *testing.T) {
+		assert.Equal(t, []int64{1}, ids(Contains("code", "50%")))
+		assert.Equal(t, []int64{3}, ids(Contains("code", "e_n")))
+		assert.Equal(t, []int64{5}, ids(StartsWith("code", `c:\`)))
+		assert.Equal(t, []int64{1, 2, 3, 4}, ids(NotEndsWith("code", "l*")))
+	})
+
+	t.Run("case-insensitive", func(t *testing.T) {
+		assert.Equal(t, []int64{1, 2}, ids(EndsWith("code", "Off")))
+		assert.Equal(t, []int64{3, 4}, ids(StartsWith("code", "SAVE")))
+		assert.Equal(t, []int64{1, 2, 5}, ids(NotContains("code", "sAvE")))
+	})
+
+	t.Run("case-sensitive", func(t *testing.T) {
+		assert.Equal(t, []int64{1}, ids(ContainsCase("code", "OFF")))
+		assert.Equal(t, []int64{2}, ids(EndsWithCase("code", "off")))
+		assert.Equal(t, []int64{5}, ids(EndsWithCase("code", "l*")))
+		assert.Equal(t, []int64{3}, ids(ContainsCase("code", "e_N")))
+		assert.Equal(t, []int64{1, 2, 5}, ids(NotStartsWithCase("code", "Save")))
+	})
+
+	t.Run("postgres uses ILIKE", func(t *testing.T) {
+		fmter := schema.NewFormatter(pgdialect.New())
+
+		b, err := Contains("code", "50%").AppendQuery(fmter, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, `"code" ILIKE '%50\%%' ESCAPE '\'`, string(b))
+
+		b, err = NotStartsWithCase("code", "a_b").AppendQuery(fmter, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, `"code" NOT LIKE 'a\_b%' ESCAPE '\'`, string(b))
+	})
+
+	t.Run("operators", func(t *testing.T) {
+		cond, err := NewCondition(OpStartsWithCase, "code", "Save")
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{3, 4}, ids(cond))
+	})
+}
+
+func TestRange(t *testing.T) {
+	type Event struct {
+		Id    int64 `bun:",pk"`
+		Seats int
+		At    time.Time
+	}
+
+	lagos, err := time.LoadLocation("Africa/Lagos") // UTC+1
+	assert.NoError(t, err)
+
+	var (
+		ctx = context.Background()
+		db  = newDB(t)
+		day = time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	)
+	err = db.ResetModel(ctx, (*Event)(nil))
+	assert.NoError(t, err)
+	defer db.NewDropTable().Model((*Event)(nil)).Exec(ctx)
+
+	events := []Event{
+		{Id: 1, Seats: 10, At: day.Add(-2 * time.Hour)},                // 9th, 23:00 in Lagos
+		{Id: 2, Seats: 20, At: day.Add(-30 * time.Minute)},             // 10th, 00:30 in Lagos
+		{Id: 3, Seats: 30, At: day.Add(12 * time.Hour).In(lagos)},      // 10th
+		{Id: 4, Seats: 40, At: day.Add(23 * time.Hour)},                // 11th, 00:00 in Lagos
+		{Id: 5, Seats: 50, At: day.AddDate(0, 0, -3).Add(time.Minute)}, // 7th
+	}
+	_, err = db.NewInsert().Model(&events).Exec(ctx)
+	assert.NoError(t, err)
+
+	ids := func(cond Condition) []int64 {
+		var got []Event
+		q := db.NewSelect().Model(&got).Order("id")
+		Where(q, cond)
+		assert.NoError(t, q.Scan(ctx))
+
+		ids := make([]int64, len(got))
+		for i := range got {
+			ids[i] = got[i].Id
+		}
+		return ids
+	}
+
+	t.Run("between", func(t *testing.T) {
+		assert.Equal(t, []int64{2, 3, 4}, ids(Between("seats", 20, 40)))
+		assert.Equal(t, []int64{1, 5}, ids(NotBetween("seats", 20, 40)))
+		assert.Equal(t, []int64{1, 2}, ids(Between("seats", nil, 20)))
+		assert.Equal(t, []int64{5}, ids(NotBetween("seats", nil, 40)))
+		assert.Nil(t, Between("seats", nil, nil))
+
+		var none *int
+		twenty := 20
+		assert.Equal(t, []int64{1, 2}, ids(Between("seats", none, &twenty)))
+		assert.Equal(t, []int64{2, 3, 4, 5}, ids(InRange("seats", &twenty, none)))
+		assert.Equal(t, []int64{1}, ids(NotBetween("seats", &twenty, none)))
+		assert.Nil(t, Between("seats", none, (*time.Time)(nil)))
+		assert.Equal(t, "seats <= 20", Between("seats", none, &twenty).String())
+	})
+
+	t.Run("half-open range", func(t *testing.T) {
+		assert.Equal(t, []int64{2, 3}, ids(InRange("seats", 20, 40)))
+		assert.Equal(t, []int64{3, 4}, ids(InRange("at", day, day.AddDate(0, 0, 1))))
+		assert.Equal(t, []int64{1, 2, 3, 4}, ids(InRange("at", day.AddDate(0, 0, -1), nil)))
+	})
+
+	t.Run("calendar days in a timezone", func(t *testing.T) {
+		assert.Equal(t, []int64{2, 3}, ids(OnDay("at", day, lagos)))
+		assert.Equal(t, []int64{3, 4}, ids(OnDay("at", day, nil)))
+		assert.Equal(t, []int64{1, 2, 3}, ids(BetweenDays("at", day.AddDate(0, 0, -1), day, lagos)))
+	})
+
+	t.Run("relative to now", func(t *testing.T) {
+		defer func(f func() time.Time) { now = f }(now)
+		now = func() time.Time { return day.Add(23*time.Hour + 30*time.Minute) }
+
+		assert.Equal(t, []int64{3, 4}, ids(WithinLast("at", 12*time.Hour)))
+		assert.Equal(t, []int64{4}, ids(WithinLastDays("at", 1, lagos)))
+		assert.Equal(t, []int64{1, 2, 3, 4}, ids(WithinLastDays("at", 3, lagos)))
+	})
+
+	t.Run("operators", func(t *testing.T) {
+		cond, err := NewCondition(OpInRange, "seats", []any{int64(20), nil})
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{2, 3, 4, 5}, ids(cond))
+
+		_, err = NewCondition(OpBetween, "seats", []any{1})
+		assert.Error(t, err)
+
+		cond, err = DecodeJSON([]byte(`{"field":"seats","op":"between","value":[10,20]}`), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{1, 2}, ids(cond))
+
+		b, err := EncodeJSON(cond)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"field":"seats","op":"between","value":[10,20]}`, string(b))
+	})
+
+	t.Run("json round trip of times", func(t *testing.T) {
+		cond := Or(InRange("at", day, day.AddDate(0, 0, 1)), Eq("at", events[4].At))
+		assert.Equal(t, []int64{3, 4, 5}, ids(cond))
+
+		b, err := EncodeJSON(cond)
+		assert.NoError(t, err)
+		assert.Contains(t, string(b), `{"$time":"2024-03-10T00:00:00Z"}`)
+
+		decoded, err := DecodeJSON(b, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{3, 4, 5}, ids(decoded))
+
+		again, err := EncodeJSON(decoded)
+		assert.NoError(t, err)
+		assert.JSONEq(t, string(b), string(again))
+
+		_, err = DecodeJSON([]byte(`{"field":"at","op":"gt","value":{"$time":"10/03/2024"}}`), nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("expressions of times", func(t *testing.T) {
+		cond := Or(InRange("at", day, day.AddDate(0, 0, 1)), Eq("at", events[4].At))
+
+		parsed, err := ParseExpr(cond.String(), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{3, 4, 5}, ids(parsed))
+		assert.Equal(t, cond.String(), fmt.Sprint(parsed))
+
+		_, err = ParseExpr(`at > time("10/03/2024")`, nil)
+		var exprErr *ExprError
+		assert.ErrorAs(t, err, &exprErr)
+	})
+}
+
+func TestSubquery(t *testing.T) {
+	type Author struct {
+		Id     int64 `bun:",pk"`
+		Name   string
+		Active bool
+	}
+	type Book struct {
+		Id       int64 `bun:",pk"`
+		AuthorId int64
+		Year     int
+	}
+
+	ctx := context.Background()
+	db := newDB(t)
+	for _, model := range []any{(*Author)(nil), (*Book)(nil)} {
+		err := db.ResetModel(ctx, model)
+		assert.NoError(t, err)
+		defer db.NewDropTable().Model(model).Exec(ctx)
+	}
+
+	reset := func() {
+		authors := []Author{{Id: 1, Name: "ann"}, {Id: 2, Name: "bob"}, {Id: 3, Name: "cy"}}
+		books := []Book{{Id: 1, AuthorId: 1, Year: 2019}, {Id: 2, AuthorId: 1, Year: 2021}, {Id: 3, AuthorId: 2, Year: 2018}}
+		_, err := db.NewInsert().Model(&authors).On("CONFLICT DO UPDATE").Set("active = FALSE").Exec(ctx)
+		assert.NoError(t, err)
+		_, err = db.NewInsert().Model(&books).On("CONFLICT DO NOTHING").Exec(ctx)
+		assert.NoError(t, err)
+	}
+
+	ids := func(cond Condition) []int64 {
+		var got []Author
+		q := db.NewSelect().Model(&got).Order("id")
+		Where(q, cond)
+		assert.NoError(t, q.Scan(ctx))
+
+		ids := make([]int64, len(got))
+		for i := range got {
+			ids[i] = got[i].Id
+		}
+		return ids
+	}
+
+	recent := From((*Book)(nil), Correlate("book.author_id", "author.id"), Gt("book.year", 2020))
+	reset()
+
+	t.Run("exists", func(t *testing.T) {
+		assert.Equal(t, []int64{1}, ids(Exists(recent)))
+		assert.Equal(t, []int64{2, 3}, ids(NotExists(recent)))
+		assert.Equal(t, []int64{3}, ids(NotExists(From((*Book)(nil), Correlate("book.author_id", "author.id")))))
+	})
+
+	t.Run("bun subquery", func(t *testing.T) {
+		sub := db.NewSelect().Model((*Book)(nil)).Column("author_id").Where("year < ?", 2020)
+		assert.Equal(t, []int64{1, 2}, ids(InSubquery("id", sub)))
+		assert.Equal(t, []int64{3}, ids(NotInSubquery("id", sub)))
+
+		correlated := db.NewSelect().Model((*Book)(nil)).ColumnExpr("1").Where("book.author_id = author.id")
+		assert.Equal(t, []int64{1, 2}, ids(Exists(correlated)))
+	})
+
+	t.Run("in groups", func(t *testing.T) {
+		cond := Or(Eq("name", "cy"), InSubquery("id", From((*Book)(nil), Eq("year", 2018)).Column("author_id")))
+		assert.Equal(t, []int64{2, 3}, ids(cond))
+		assert.Equal(t, []int64{2, 3}, ids(Not(Exists(recent))))
+	})
+
+	t.Run("update and delete", func(t *testing.T) {
+		defer reset()
+
+		uq := db.NewUpdate().Model((*Author)(nil)).Set("active = TRUE")
+		Where(uq, Exists(recent))
+		_, err := uq.Exec(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{1}, ids(Eq("active", true)))
+
+		dq := db.NewDelete().Model((*Author)(nil))
+		Where(dq, NotExists(From((*Book)(nil), Correlate("book.author_id", "author.id"))))
+		_, err = dq.Exec(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{1, 2}, ids(nil))
+	})
+
+	t.Run("builder checks the outer column", func(t *testing.T) {
+		b := NewBuilder(db.NewSelect().Model((*Author)(nil))).
+			Where(InSubquery("author_id", From((*Book)(nil)).Column("author_id")))
+		assert.Error(t, b.Err())
+	})
+}
+
+func TestRelationColumns(t *testing.T) {
+	type Label struct {
+		Id   int64 `bun:",pk"`
+		Name string
+	}
+	type Track struct {
+		Id      int64 `bun:",pk"`
+		AlbumId int64
+	}
+	type Artist struct {
+		Id      int64 `bun:",pk"`
+		Name    string
+		LabelId int64
+		Label   *Label `bun:"rel:belongs-to,join:label_id=id"`
+	}
+	type Album struct {
+		Id       int64 `bun:",pk"`
+		Title    string
+		ArtistId int64
+		Artist   *Artist  `bun:"rel:belongs-to,join:artist_id=id"`
+		Tracks   []*Track `bun:"rel:has-many,join:id=album_id"`
+	}
+
+	ctx := context.Background()
+	db := newDB(t)
+	for _, model := range []any{(*Label)(nil), (*Artist)(nil), (*Album)(nil)} {
+		err := db.ResetModel(ctx, model)
+		assert.NoError(t, err)
+		defer db.NewDropTable().Model(model).Exec(ctx)
+	}
+
+	for _, rows := range []any{
+		&[]Label{{Id: 1, Name: "indie"}, {Id: 2, Name: "major"}},
+		&[]Artist{{Id: 1, Name: "ann", LabelId: 1}, {Id: 2, Name: "bob", LabelId: 2}},
+		&[]Album{{Id: 1, Title: "a", ArtistId: 1}, {Id: 2, Title: "b", ArtistId: 2}, {Id: 3, Title: "c", ArtistId: 1}},
+	} {
+		_, err := db.NewInsert().Model(rows).Exec(ctx)
+		assert.NoError(t, err)
+	}
+
+	scan := func(q *bun.SelectQuery, albums *[]Album) []int64 {
+		assert.NoError(t, q.Order("album.id").Scan(ctx))
+
+		ids := make([]int64, len(*albums))
+		for i, album := range *albums {
+			ids[i] = album.Id
+		}
+		return ids
+	}
+
+	t.Run("joins the relation once", func(t *testing.T) {
+		var albums []Album
+		q := db.NewSelect().Model(&albums)
+		Where(q, Eq("Artist.name", "ann"))
+		Where(q, Not(Eq("Artist.name", "bob")))
+		OrWhere(q, Contains("Artist.name", "zed"))
+
+		assert.Equal(t, 1, strings.Count(q.String(), `JOIN "artists"`))
+		assert.Equal(t, []int64{1, 3}, scan(q, &albums))
+		assert.Equal(t, "ann", albums[0].Artist.Name)
+	})
+
+	t.Run("nested paths and groups", func(t *testing.T) {
+		var albums []Album
+		q := db.NewSelect().Model(&albums)
+		Where(q, Or(Eq("Artist.Label.name", "major"), Eq("title", "c")))
+
+		assert.Contains(t, q.String(), `"artist__label"."name" = 'major'`)
+		assert.Equal(t, []int64{2, 3}, scan(q, &albums))
+	})
+
+	t.Run("relation joined by the caller", func(t *testing.T) {
+		var albums []Album
+		q := db.NewSelect().Model(&albums).Relation("Artist")
+		Where(q, In("Artist.id", []int64{2}))
+
+		assert.Equal(t, 1, strings.Count(q.String(), `JOIN "artists"`))
+		assert.Equal(t, []int64{2}, scan(q, &albums))
+	})
+
+	t.Run("unknown paths are rejected", func(t *testing.T) {
+		var albums []Album
+		q := db.NewSelect().Model(&albums)
+		Where(q, Eq("Artst.name", "ann"))
+		var ferr *FieldError
+		assert.ErrorAs(t, q.Scan(ctx), &ferr)
+		assert.Equal(t, "Artst.name", ferr.Param)
+
+		q = db.NewSelect().Model(&albums)
+		OrWhere(q, Eq("Tracks.id", 1))
+		assert.Error(t, q.Scan(ctx))
+
+		_, err := db.NewUpdate().Model(&Album{Id: 1}).Set("title = 'x'").WherePK().
+			Apply(UpdateWhere(Eq("Artist.name", "ann"))).Exec(ctx)
+		assert.ErrorAs(t, err, &ferr)
+
+		q = db.NewSelect().Model(&albums).Apply(SelectWhere(Eq("Artist.nope.name", "ann")))
+		assert.Error(t, q.Scan(ctx))
+
+		b := NewBuilder(db.NewSelect().Model((*Album)(nil))).
+			Where(Eq("Artist.nope", 1)).
+			Where(Eq("Artist.Labl.name", "indie")).
+			Where(Eq("Artist.Label.name", "indie")).
+			OrWhere(Eq("Artist.Label.id", 1))
+
+		var verr ValidationErrors
+		assert.ErrorAs(t, b.Err(), &verr)
+		assert.Len(t, verr, 2)
+		assert.Equal(t, "Artist.nope", verr[0].Param)
+		assert.Equal(t, 1, strings.Count(b.Query().String(), `JOIN "labels"`))
+	})
+}
+
+func TestJSONColumn(t *testing.T) {
+	type Product struct {
+		Id    int64          `bun:",pk"`
+		Attrs map[string]any `bun:"type:json"`
+	}
+
+	ctx := context.Background()
+	db := newDB(t)
+	err := db.ResetModel(ctx, (*Product)(nil))
+	assert.NoError(t, err)
+	defer db.NewDropTable().Model((*Product)(nil)).Exec(ctx)
+
+	products := []Product{
+		{Id: 1, Attrs: map[string]any{"color": "red", "size": map[string]any{"width": 10}, "tags": []any{"new", "sale"}}},
+		{Id: 2, Attrs: map[string]any{"color": "blue", "size": map[string]any{"width": 25, "depth": nil}, "tags": []any{"sale"}}},
+		{Id: 3, Attrs: map[string]any{"color": "red", "discontinued": true}},
+	}
+	_, err = db.NewInsert().Model(&products).Exec(ctx)
+	assert.NoError(t, err)
+
+	ids := func(cond Condition) []int64 {
+		var got []Product
+		q := db.NewSelect().Model(&got).Order("id")
+		Where(q, cond)
+		assert.NoError(t, q.Scan(ctx))
+
+		ids := make([]int64, len(got))
+		for i := range got {
+			ids[i] = got[i].Id
+		}
+		return ids
+	}
+
+	t.Run("compare at a path", func(t *testing.T) {
+		assert.Equal(t, []int64{1, 3}, ids(JSONEq("attrs", "color", "red")))
+		assert.Equal(t, []int64{2}, ids(JSONCompare("attrs", "size.width", OpGreaterThan, 20)))
+		assert.Equal(t, []int64{3}, ids(JSONEq("attrs", "discontinued", true)))
+		assert.Equal(t, []int64{2}, ids(JSONEq("attrs", "tags.0", "sale")))
+		assert.Panics(t, func() { JSONCompare("attrs", "color", OpContains, "r") })
+	})
+
+	t.Run("has key", func(t *testing.T) {
+		assert.Equal(t, []int64{2}, ids(JSONHasKey("attrs", "size.depth")))
+		assert.Equal(t, []int64{1, 2}, ids(JSONHasKey("attrs", "tags")))
+		assert.Equal(t, []int64{3}, ids(Not(JSONHasKey("attrs", "size"))))
+	})
+
+	t.Run("contains", func(t *testing.T) {
+		assert.Equal(t, []int64{1}, ids(JSONContains("attrs", "", map[string]any{"color": "red", "size": map[string]any{"width": 10}})))
+		assert.Equal(t, []int64{2}, ids(JSONContains("attrs", "size", map[string]any{"depth": nil})))
+		assert.Equal(t, []int64{1, 2}, ids(JSONContains("attrs", "", map[string]any{"tags": []string{"sale"}})))
+		assert.Equal(t, []int64{1}, ids(JSONArrayContains("attrs", "tags", "new")))
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		fmter := schema.NewFormatter(pgdialect.New())
+		render := func(cond Condition) string {
+			b, err := cond.AppendQuery(fmter, nil)
+			assert.NoError(t, err)
+			return string(b)
+		}
+
+		assert.Equal(t, `("attrs"->'size'->>'width')::numeric > 20`, render(JSONCompare("attrs", "size.width", OpGreaterThan, 20)))
+		assert.Equal(t, `("attrs"->'tags'->>0) = 'sale'`, render(JSONEq("attrs", "tags.0", "sale")))
+		assert.Equal(t, `"attrs"->'size' ? 'depth'`, render(JSONHasKey("attrs", "size.depth")))
+		assert.Equal(t, `"attrs" @> CAST('{"color":"red"}' AS jsonb)`, render(JSONContains("attrs", "", map[string]any{"color": "red"})))
+		assert.Equal(t, `"attrs"->'tags' @> CAST('["new"]' AS jsonb)`, render(JSONArrayContains("attrs", "tags", "new")))
+	})
+
+	t.Run("operators", func(t *testing.T) {
+		conds := []Condition{
+			JSONCompare("attrs", "size.width", OpGreaterThan, 20),
+			JSONHasKey("attrs", "size.depth"),
+			JSONContains("attrs", "size", map[string]any{"width": 10}),
+			JSONArrayContains("attrs", "tags", "new"),
+		}
+		for _, cond := range conds {
+			b, err := EncodeJSON(cond)
+			assert.NoError(t, err)
+			decoded, err := DecodeJSON(b, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, ids(cond), ids(decoded), string(b))
+
+			parsed, err := ParseExpr(fmt.Sprint(cond), nil)
+			assert.NoError(t, err)
+			assert.Equal(t, ids(cond), ids(parsed), fmt.Sprint(cond))
+
+			var evaluated []int64
+			for _, p := range products {
+				if ok, err := Evaluate(cond, p); assert.NoError(t, err) && ok {
+					evaluated = append(evaluated, p.Id)
+				}
+			}
+			assert.Equal(t, ids(cond), evaluated, fmt.Sprint(cond))
+		}
+
+		_, err := NewCondition(OpJSONContains, "attrs", []any{"size", "{"})
+		assert.Error(t, err)
+		_, err = NewCondition(OpJSONCompare, "attrs", []any{"size", "like", 1})
+		assert.Error(t, err)
+	})
+}
+
+func TestArray(t *testing.T) {
+	type Article struct {
+		Id   int64    `bun:",pk"`
+		Tags []string `bun:",array"`
+	}
+
+	ctx := context.Background()
+	db := newDB(t)
+	err := db.ResetModel(ctx, (*Article)(nil))
+	assert.NoError(t, err)
+	defer db.NewDropTable().Model((*Article)(nil)).Exec(ctx)
+
+	articles := []Article{
+		{Id: 1, Tags: []string{"go", "sql"}},
+		{Id: 2, Tags: []string{"go"}},
+		{Id: 3, Tags: []string{"rust", "sql", "wasm"}},
+		{Id: 4, Tags: []string{}},
+	}
+	_, err = db.NewInsert().Model(&articles).Exec(ctx)
+	assert.NoError(t, err)
+
+	ids := func(cond Condition) []int64 {
+		var got []Article
+		q := db.NewSelect().Model(&got).Order("id")
+		Where(q, cond)
+		assert.NoError(t, q.Scan(ctx))
+
+		ids := make([]int64, len(got))
+		for i := range got {
+			ids[i] = got[i].Id
+		}
+		return ids
+	}
+
+	t.Run("sqlite emulation", func(t *testing.T) {
+		assert.Equal(t, []int64{1, 2, 3}, ids(ArrayOverlaps("tags", []string{"go", "wasm"})))
+		assert.Equal(t, []int64{1, 3}, ids(ArrayContainsAny("tags", []string{"sql"})))
+		assert.Equal(t, []int64{1}, ids(ArrayContainsAll("tags", []string{"sql", "go", "go"})))
+		assert.Equal(t, []int64{3, 4}, ids(Not(ArrayContainsAny("tags", []string{"go"}))))
+		assert.Equal(t, []int64{1, 3}, ids(ArrayLength("tags", OpGreaterThanOrEqual, 2)))
+		assert.Equal(t, []int64{4}, ids(ArrayLength("tags", OpEqual, 0)))
+		assert.True(t, ArrayLength("tags", OpGreaterThanOrEqual, 2).IsSatisfiedBy(articles[2]))
+		assert.False(t, ArrayLength("tags", OpGreaterThanOrEqual, 2).IsSatisfiedBy(articles[1]))
+		assert.Nil(t, ArrayOverlaps("tags", []string{}))
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		fmter := schema.NewFormatter(pgdialect.New())
+		render := func(cond Condition) string {
+			b, err := cond.AppendQuery(fmter, nil)
+			assert.NoError(t, err)
+			return string(b)
+		}
+
+		assert.Equal(t, `"tags" && '{"go","wasm"}'`, render(ArrayOverlaps("tags", []string{"go", "wasm"})))
+		assert.Equal(t, `"tags" @> '{"sql","go"}'`, render(ArrayContainsAll("tags", []string{"sql", "go"})))
+		assert.Equal(t, `cardinality("tags") > 1`, render(ArrayLength("tags", OpGreaterThan, 1)))
+
+		// the parsers build a []any
+		assert.Equal(t, `"tags" && '{"go","wasm"}'`, render(ArrayOverlaps("tags", []any{"go", "wasm"})))
+		assert.Equal(t, `"ids" @> '{1,2}'`, render(ArrayContainsAll("ids", []any{int64(1), int64(2)})))
+		assert.Equal(t, `"ids" && ARRAY[1, 2.5]`, render(ArrayOverlaps("ids", []any{int64(1), 2.5})))
+
+		cond, err := NewCondition(OpArrayContainsAny, "tags", []any{"sql", "go"})
+		assert.NoError(t, err)
+		assert.Equal(t, `"tags" && '{"sql","go"}'`, render(cond))
+
+		cond, err = ParseExpr(`tags hasall ("sql", "go")`, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, `"tags" @> '{"sql","go"}'`, render(cond))
+	})
+
+	t.Run("unhashable values", func(t *testing.T) {
+		assert.Equal(t, 2, countDistinct([]any{[]int{1}, []int{1}, "go"}))
+		assert.Equal(t, 3, countDistinct([]any{struct{ V any }{[]int{1}}, struct{ V any }{[]int{1}}, nil, 1}))
+	})
+
+	t.Run("query string", func(t *testing.T) {
+		p, err := NewQueryParser(db, (*Article)(nil), Allowlist{"tags": {OpArrayContainsAll}})
+		assert.NoError(t, err)
+
+		pq, err := p.Parse(url.Values{"tags[hasall]": {"sql,rust"}})
+		assert.NoError(t, err)
+		assert.Equal(t, []int64{3}, ids(And(pq.Conditions...)))
+
+		b, err := pq.Conditions[0].AppendQuery(schema.NewFormatter(pgdialect.New()), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, `"tags" @> '{"sql","rust"}'`, string(b))
+	})
+}
+
+func TestSearch(t *testing.T) {
+	type Note struct {
+		Id    int64 `bun:",pk"`
+		Title string
+		Body  string
+	}
+
+	ctx := context.Background()
+	db := newDB(t)
+	err := db.ResetModel(ctx, (*Note)(nil))
+	assert.NoError(t, err)
+	defer db.NewDropTable().Model((*Note)(nil)).Exec(ctx)
+	defer db.ExecContext(ctx, "DROP TABLE IF EXISTS notes_fts")
+
+	err = seeder.NewSeeder(db).CreateSearchIndex(ctx, (*Note)(nil), "title", "body")
+	assert.NoError(t, err)
+
+	notes := []Note{
+		{Id: 1, Title: "Go databases", Body: "sql and go"},
+		{Id: 2, Title: "Cooking", Body: "pasta, no databases"},
+		{Id: 3, Title: "Databases", Body: "databases, databases and more databases"},
+		{Id: 4, Title: `Quotes "and" NOT operators`, Body: "c++ & friends"},
+	}
+	_, err = db.NewInsert().Model(&notes).Exec(ctx)
+	assert.NoError(t, err)
+
+	ids := func(search *FullTextSearch, rank bool) []int64 {
+		var got []Note
+		q := db.NewSelect().Model(&got)
+		Where(q, search)
+		if rank {
+			search.OrderByRank(q)
+		}
+		assert.NoError(t, q.Order("id").Scan(ctx))
+
+		ids := make([]int64, len(got))
+		for i := range got {
+			ids[i] = got[i].Id
+		}
+		return ids
+	}
+
+	t.Run("every word, the last as a prefix", func(t *testing.T) {
+		assert.Equal(t, []int64{1, 2, 3}, ids(Search((*Note)(nil), "database", "title", "body"), false))
+		assert.Equal(t, []int64{1}, ids(Search((*Note)(nil), "go dat", "title", "body"), false))
+		assert.Equal(t, []int64{1, 2, 3}, ids(Search((*Note)(nil), "DATA", "title", "body"), false))
+	})
+
+	t.Run("rank", func(t *testing.T) {
+		assert.Equal(t, int64(3), ids(Search((*Note)(nil), "databases", "title", "body"), true)[0])
+	})
+
+	t.Run("sanitized", func(t *testing.T) {
+		assert.Equal(t, []int64{4}, ids(Search((*Note)(nil), `"and" NOT`, "title", "body"), false))
+		assert.Equal(t, []int64{4}, ids(Search((*Note)(nil), `c++ & (friends`, "title", "body"), false))
+		assert.True(t, isEmpty(Search((*Note)(nil), `"* &`, "title", "body")))
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		db := bun.NewDB(nil, pgdialect.New())
+		q := db.NewSelect().Model((*Note)(nil))
+		search := Search((*Note)(nil), `go "dat`, "title", "body")
+		Where(q, search)
+		search.OrderByRank(q)
+
+		vector := `to_tsvector('english', coalesce("note"."title", '') || ' ' || coalesce("note"."body", ''))`
+		assert.Contains(t, q.String(), `WHERE (`+vector+` @@ to_tsquery('english', 'go & dat:*'))`)
+		assert.Contains(t, q.String(), `ORDER BY ts_rank(`+vector+`, to_tsquery('english', 'go & dat:*')) DESC`)
+	})
+}
+
+func TestFreeText(t *testing.T) {
+	type Member struct {
+		Id    int64 `bun:",pk"`
+		Name  string
+		Email string
+		Admin bool
+	}
+
+	ctx := context.Background()
+	db := newDB(t)
+	err := db.ResetModel(ctx, (*Member)(nil))
+	assert.NoError(t, err)
+	defer db.NewDropTable().Model((*Member)(nil)).Exec(ctx)
+
+	members := []Member{
+		{Id: 1, Name: "Ann Smith", Email: "ann@example.com", Admin: true},
+		{Id: 2, Name: "Bob Smith", Email: "bob@ann.dev"},
+		{Id: 3, Name: "Ann Lee", Email: "lee@example.com"},
+	}
+	_, err = db.NewInsert().Model(&members).Exec(ctx)
+	assert.NoError(t, err)
+
+	ids := func(q *bun.SelectQuery) []int64 {
+		var got []Member
+		assert.NoError(t, q.Model(&got).Order("id").Scan(ctx))
+
+		ids := make([]int64, len(got))
+		for i := range got {
+			ids[i] = got[i].Id
+		}
+		return ids
+	}
+
+	t.Run("every word in any column", func(t *testing.T) {
+		q := db.NewSelect()
+		Where(q, FreeText("  SMITH ann ", "name", "email"))
+		assert.Equal(t, []int64{1, 2}, ids(q))
+
+		q = db.NewSelect()
+		Where(q, FreeText("ann ANN", "name"))
+		assert.Equal(t, []int64{1, 3}, ids(q))
+		assert.Equal(t, 1, strings.Count(q.String(), "LIKE"))
+	})
+
+	t.Run("grouped", func(t *testing.T) {
+		q := db.NewSelect()
+		Where(q, FreeText("ann", "name", "email"))
+		Where(q, Eq("admin", false))
+		assert.Equal(t, []int64{2, 3}, ids(q))
+	})
+
+	t.Run("empty query is skipped", func(t *testing.T) {
+		q := db.NewSelect()
+		Where(q, FreeText("   ", "name", "email"))
+		assert.NotContains(t, q.String(), "WHERE")
+	})
+
+	t.Run("query string", func(t *testing.T) {
+		p, err := NewQueryParser(db, (*Member)(nil), Allowlist{"admin": {OpEqual}}, WithSearch("name", "email"))
+		assert.NoError(t, err)
+
+		pq, err := p.Parse(url.Values{"q": {"smith ann"}, "admin": {"false"}})
+		assert.NoError(t, err)
+
+		q := db.NewSelect().Model((*Member)(nil))
+		pq.Apply(q)
+		assert.Equal(t, []int64{2}, ids(q))
+
+		_, err = NewQueryParser(db, (*Member)(nil), nil, WithSearch("nickname"))
+		assert.Error(t, err)
+	})
+}
+
+func TestMatches(t *testing.T) {
+	type Account struct {
+		Id       int64 `bun:",pk"`
+		Username string
+	}
+
+	ctx := context.Background()
+	db, err := dbstore.NewDBConnection(dbstore.DriverSqlite, "file:regexp?mode=memory&cache=shared", 1, false)
+	assert.NoError(t, err)
+	defer db.Close()
+
+	err = db.ResetModel(ctx, (*Account)(nil))
+	assert.NoError(t, err)
+
+	accounts := []Account{{Id: 1, Username: "crawler_bot"}, {Id: 2, Username: "ann"}, {Id: 3, Username: "IndexBOT"}, {Id: 4, Username: "bob42"}}
+	_, err = db.NewInsert().Model(&accounts).Exec(ctx)
+	assert.NoError(t, err)
+
+	ids := func(cond Condition) []int64 {
+		var got []Account
+		q := db.NewSelect().Model(&got).Order("id")
+		Where(q, cond)
+		assert.NoError(t, q.Scan(ctx))
+
+		ids := make([]int64, len(got))
+		for i := range got {
+			ids[i] = got[i].Id
+		}
+		return ids
+	}
+
+	t.Run("sqlite regexp function", func(t *testing.T) {
+		assert.Equal(t, []int64{1}, ids(Matches("username", "bot$")))
+		assert.Equal(t, []int64{1, 3}, ids(MatchesFold("username", "bot$")))
+		assert.Equal(t, []int64{2, 3, 4}, ids(NotMatches("username", "bot$")))
+		assert.Equal(t, []int64{2, 4}, ids(NotMatchesFold("username", "BOT")))
+		assert.Equal(t, []int64{4}, ids(Matches("username", `^[a-z]+\d+$`)))
+	})
+
+	t.Run("null gives null", func(t *testing.T) {
+		for _, query := range []string{"SELECT NULL REGEXP 'nil'", "SELECT 'nil' REGEXP NULL"} {
+			var got sql.NullBool
+			assert.NoError(t, db.QueryRowContext(ctx, query).Scan(&got), query)
+			assert.False(t, got.Valid, query)
+		}
+	})
+
+	t.Run("invalid pattern", func(t *testing.T) {
+		var got []Account
+		q := db.NewSelect().Model(&got)
+		Where(q, Matches("username", "(bot"))
+		assert.Error(t, q.Scan(ctx))
+
+		_, err := NewCondition(OpMatches, "username", "(bot")
+		assert.Error(t, err)
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		fmter := schema.NewFormatter(pgdialect.New())
+		b, err := NotMatchesFold("username", "bot$").AppendQuery(fmter, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, `"username" !~* 'bot$'`, string(b))
+
+		b, err = Matches("username", "bot$").AppendQuery(fmter, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, `"username" ~ 'bot$'`, string(b))
+	})
+}
+
+func TestParseExpr(t *testing.T) {
+	type Member struct {
+		bun.BaseModel `bun:"table:expr_members,alias:member"`
+		ID            int64 `bun:",pk"`
+		Status        string
+		Age           int
+		Name          *string
+	}
+
+	var (
+		ctx  = context.Background()
+		db   = newDB(t)
+		name = func(s string) *string { return &s }
+	)
+	assert.NoError(t, db.ResetModel(ctx, (*Member)(nil)))
+
+	seed := []Member{
+		{ID: 1, Status: "active", Age: 30, Name: name("Ann")},
+		{ID: 2, Status: "invited", Age: 17, Name: name("Bob")},
+		{ID: 3, Status: "banned", Age: 40},
+		{ID: 4, Status: "active", Age: 18, Name: name("crawler")},
+	}
+	_, err := db.NewInsert().Model(&seed).Exec(ctx)
+	assert.NoError(t, err)
+
+	ids := func(expr string) []int64 {
+		cond, err := ParseExpr(expr, nil)
+		assert.NoError(t, err)
+
+		var got []Member
+		q := db.NewSelect().Model(&got).Order("id")
+		Where(q, cond)
+		assert.NoError(t, q.Scan(ctx))
+
+		out := make([]int64, len(got))
+		for i := range got {
+			out[i] = got[i].ID
+		}
+		return out
+	}
+
+	t.Run("evaluates", func(t *testing.T) {
+		assert.Equal(t, []int64{1, 4}, ids(`status in ("active", "invited") and age >= 18`))
+		assert.Equal(t, []int64{2, 3}, ids(`status NOT IN ("active") OR age < 0`))
+		assert.Equal(t, []int64{1, 3}, ids(`not (age between 17 and 18) and (name is null or name starts "a")`))
+		assert.Equal(t, []int64{2, 4}, ids(`name is not null and not name contains "n" or age == 18`))
+		assert.Equal(t, []int64{2}, ids(`age <> 30 and name ends "B"`))
+	})
+
+	t.Run("renders", func(t *testing.T) {
+		cond, err := ParseExpr(`status in ("a","b") and age >= 18 and not name ~ "bot"`, nil)
+		assert.NoError(t, err)
+
+		b, err := cond.AppendQuery(schema.NewFormatter(pgdialect.New()), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, `(("status" IN ('a', 'b')) AND ("age" >= 18) AND (NOT ("name" ~ 'bot')))`, string(b))
+	})
+
+	t.Run("typed literals", func(t *testing.T) {
+		cond, err := ParseExpr(`score > -2.5 and verified = true and note = "say \"hi\"" and age in (1, 2)`, nil)
+		assert.NoError(t, err)
+
+		encoded, err := EncodeJSON(cond)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"and": [
+			{"field": "score", "op": "gt", "value": -2.5},
+			{"field": "verified", "op": "eq", "value": true},
+			{"field": "note", "op": "eq", "value": "say \"hi\""},
+			{"field": "age", "op": "in", "value": [1, 2]}
+		]}`, string(encoded))
+	})
+
+	t.Run("operators by name and open ranges", func(t *testing.T) {
+		cond, err := ParseExpr(`age not between 1 and 5 and age between null and 9 and name containscs "Bo" and tags HASANY ("a", "b") and score range 1 and 2`, nil)
+		assert.NoError(t, err)
+
+		encoded, err := EncodeJSON(cond)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"and": [
+			{"field": "age", "op": "nbetween", "value": [1, 5]},
+			{"field": "age", "op": "lte", "value": 9},
+			{"field": "name", "op": "containscs", "value": "Bo"},
+			{"field": "tags", "op": "hasany", "value": ["a", "b"]},
+			{"field": "score", "op": "range", "value": [1, 2]}
+		]}`, string(encoded))
+	})
+
+	t.Run("errors carry the position", func(t *testing.T) {
+		allow := Allowlist{"age": {OpGreaterThanOrEqual}, "name": {OpMatches, OpIsNull}}
+
+		tests := map[string]int{
+			`age >= 18 and`:              14,
+			`age >= 18 and status = "a"`: 15,
+			`age < 18`:                   5,
+			`(age >= 18`:                 11,
+			`name ~ "(bot"`:              8,
+			`name is nul`:                9,
+			`name = null`:                6,
+			`age >= "18`:                 8,
+			`age >= 18 # x`:              11,
+			`age >= 18 18`:               11,
+			`and >= 1`:                   1,
+		}
+		for expr, pos := range tests {
+			_, err := ParseExpr(expr, allow)
+			var exprErr *ExprError
+			if assert.ErrorAs(t, err, &exprErr, expr) {
+				assert.Equal(t, pos, exprErr.Pos, "%s: %s", expr, err)
+			}
+		}
+
+		_, err := ParseExpr(`name ~ "bot" and name is null`, allow)
+		assert.NoError(t, err)
+	})
+}
+
+func TestFieldCondition(t *testing.T) {
+	t.Run("accessors", func(t *testing.T) {
+		cond := Contains("name", "bo")
+		assert.Equal(t, "name", cond.Field())
+		assert.Equal(t, OpContains, cond.Op())
+		assert.Equal(t, "bo", cond.Value())
+
+		assert.Equal(t, true, IsNull("email").Value())
+		assert.Equal(t, []any{1, 2}, Between("age", 1, 2).Value())
+		assert.Equal(t, OpJSONCompare, JSONEq("attrs", "size", 1).Op())
+		assert.Equal(t, []any{"size", OpEqual, 1}, JSONEq("attrs", "size", 1).Value())
+		assert.Equal(t, "size.depth", JSONHasKey("attrs", "size.depth").Value())
+		assert.Equal(t, []any{"", `{"color":"red"}`}, JSONContains("attrs", "", map[string]any{"color": "red"}).Value())
+		assert.Equal(t, OpArrayLength, ArrayLength("tags", OpGreaterThan, 1).Op())
+		assert.Equal(t, []any{OpGreaterThan, 1}, ArrayLength("tags", OpGreaterThan, 1).Value())
+	})
+
+	t.Run("string reads back with ParseExpr", func(t *testing.T) {
+		tests := []Condition{
+			Eq("age", 18),
+			Gte("score", 2.0),
+			NEq("status", "say \"hi\""),
+			In("status", []string{"a", "b"}),
+			NotIn("age", []int{1, 2}),
+			IsNotNull("email"),
+			NotBetween("age", 18, nil),
+			InRange("created_at", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)),
+			ContainsCase("name", "Bo"),
+			MatchesFold("name", "bot$"),
+			ArrayContainsAny("tags", []string{"go"}),
+			ArrayLength("tags", OpGreaterThanOrEqual, 2),
+			JSONCompare("attrs", "size.width", OpLessThan, 2.5),
+			JSONHasKey("attrs", "size"),
+			JSONContains("attrs", "tags", []string{"new"}),
+			And(Gte("age", 18), Or(IsNull("name"), Not(Contains("name", "bot"))), Not(And(Eq("a", true), Eq("b", false)))),
+		}
+
+		for _, cond := range tests {
+			s := fmt.Sprint(cond)
+			parsed, err := ParseExpr(s, nil)
+			if assert.NoError(t, err, s) {
+				assert.Equal(t, s, fmt.Sprint(parsed))
+			}
+		}
+
+		assert.Equal(t, `age >= 18 and (name is null or not name contains "bot")`,
+			And(Gte("age", 18), Or(IsNull("name"), Not(Contains("name", "bot")))).String())
+		assert.Equal(t, `created_at >= time("2024-01-01T00:00:00Z")`,
+			Between("created_at", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), nil).String())
+		assert.Equal(t, `attrs json ("size", "eq", "1")`, JSONEq("attrs", "size", "1").String())
+		assert.Equal(t, `attrs jsoncontains ("", "{\"color\":\"red\"}")`, JSONContains("attrs", "", map[string]any{"color": "red"}).String())
+	})
+
+	t.Run("walk", func(t *testing.T) {
+		cond := And(Gte("age", 18), Or(IsNull("name"), Not(Contains("email", "bot"))), Eq("age", nil))
+
+		var columns []string
+		Inspect(cond, func(cond Condition) bool {
+			if c, ok := cond.(*FieldCondition); ok {
+				columns = append(columns, c.Field())
+			}
+			return true
+		})
+		assert.Equal(t, []string{"age", "name", "email"}, columns)
+
+		// skip what is negated
+		columns = nil
+		Inspect(cond, func(cond Condition) bool {
+			if c, ok := cond.(*FieldCondition); ok {
+				columns = append(columns, c.Field())
+			}
+			_, negated := cond.(*Negation)
+			return !negated
+		})
+		assert.Equal(t, []string{"age", "name"}, columns)
+	})
+}
+
+func TestCriteria(t *testing.T) {
+	var (
+		ctx  = context.Background()
+		db   = newDB(t)
+		repo = obun.NewRepository(db)
+		err  = resetDB(ctx, db)
+	)
+	assert.NoError(t, err)
+
+	names := func(users []User) []string {
+		out := make([]string, len(users))
+		for i := range users {
+			out[i] = users[i].Name
+		}
+		return out
+	}
+
+	t.Run("select", func(t *testing.T) {
+		var users []User
+		err := repo.FindManyWhere(ctx, &users, nil,
+			SelectWhere(NEq("id", "_id_2"), Or(Contains("name", "_3"), EndsWith("email", "_4"), Eq("id", "_id_1"))),
+			func(q *bun.SelectQuery) *bun.SelectQuery { return q.Where("? != ?", bun.Ident("id"), "_id_1") },
+			SortBy(SortSpec{{Column: "name", Desc: true}}),
+			LimitTo(1),
+		)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"google_4"}, names(users))
+
+		var user User
+		err = repo.FindOneWhere(ctx, &user, SelectWhere(Eq("phone", "123456789_3")))
+		assert.NoError(t, err)
+		assert.Equal(t, "_id_3", user.Id)
+	})
+
+	t.Run("parsed query", func(t *testing.T) {
+		p, err := NewQueryParser(db, User{}, Allowlist{"name": {OpContains}})
+		assert.NoError(t, err)
+		pq, err := p.Parse(url.Values{"name[contains]": {"google"}, "sort": {"-name"}, "limit": {"2"}})
+		assert.NoError(t, err)
+
+		var users []User
+		assert.NoError(t, repo.FindManyWhere(ctx, &users, nil, pq.Criteria()))
+		assert.Equal(t, []string{"google_4", "google_3"}, names(users))
+	})
+
+	t.Run("update and delete", func(t *testing.T) {
+		err := repo.UpdateOneWhere(ctx, &User{Phone: "000"},
+			func(q *bun.UpdateQuery) *bun.UpdateQuery { return q.Column("phone") },
+			UpdateWhere(In("id", []string{"_id_1", "_id_2"})),
+		)
+		assert.NoError(t, err)
+
+		err = repo.DeleteWhere(ctx, (*User)(nil), DeleteWhere(Eq("phone", "000"), NEq("id", "_id_1")))
+		assert.NoError(t, err)
+
+		var users []User
+		assert.NoError(t, repo.FindManyWhere(ctx, &users, nil, SortBy(SortSpec{{Column: "id"}})))
+		assert.Equal(t, []string{"google_1", "google_3", "google_4"}, names(users))
+		assert.Equal(t, "000", users[0].Phone)
+	})
+}
+
+func TestSpecification(t *testing.T) {
+	type Customer struct {
+		ID   int64 `bun:",pk"`
+		Name string
+	}
+	type Invoice struct {
+		bun.BaseModel `bun:"table:spec_invoices,alias:invoice"`
+		ID            int64 `bun:",pk"`
+		CustomerID    int64
+		Customer      *Customer `bun:"rel:belongs-to,join:customer_id=id"`
+		Amount        float64
+		Status        string
+		Tags          []string `bun:",array"`
+		DueAt         time.Time
+		PaidAt        *time.Time
+	}
+
+	var (
+		ctx   = context.Background()
+		db    = newDB(t)
+		today = time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+		paid  = today.AddDate(0, 0, -3)
+	)
+	assert.NoError(t, db.ResetModel(ctx, (*Customer)(nil), (*Invoice)(nil)))
+
+	customers := []Customer{{ID: 1, Name: "Ann"}, {ID: 2, Name: "Bob"}}
+	invoices := []Invoice{
+		{ID: 1, CustomerID: 1, Amount: 120, Status: "open", Tags: []string{"eu"}, DueAt: today.AddDate(0, 0, -5)},
+		{ID: 2, CustomerID: 1, Amount: 80, Status: "paid", Tags: []string{"us", "vip"}, DueAt: today.AddDate(0, 0, -5), PaidAt: &paid},
+		{ID: 3, CustomerID: 2, Amount: 300.5, Status: "open", DueAt: today.AddDate(0, 0, 5)},
+		{ID: 4, CustomerID: 2, Amount: 50, Status: "Open", Tags: []string{"vip"}, DueAt: today.AddDate(0, 0, -1)},
+	}
+	_, err := db.NewInsert().Model(&customers).Exec(ctx)
+	assert.NoError(t, err)
+	_, err = db.NewInsert().Model(&invoices).Exec(ctx)
+	assert.NoError(t, err)
+
+	overdue := dbstore.NewSpecification(
+		func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("paid_at IS NULL").Where("due_at < ?", today)
+		},
+		func(inv *Invoice) bool {
+			return inv.PaidAt == nil && inv.DueAt.Before(today)
+		},
+	)
+
+	// checks that the rows selected by the spec are the ones satisfying it in Go
+	check := func(spec dbstore.Specification, want []int64) {
+		var got []Invoice
+		q := db.NewSelect().Model(&got).Relation("Customer").Order("invoice.id")
+		spec.Criteria()(q)
+		assert.NoError(t, q.Scan(ctx))
+
+		selected := make([]int64, len(got))
+		for i := range got {
+			selected[i] = got[i].ID
+		}
+		assert.Equal(t, want, selected, "selected")
+
+		var loaded []Invoice
+		assert.NoError(t, db.NewSelect().Model(&loaded).Relation("Customer").Order("invoice.id").Scan(ctx))
+
+		var satisfied []int64
+		for i := range loaded {
+			if spec.IsSatisfiedBy(&loaded[i]) {
+				satisfied = append(satisfied, loaded[i].ID)
+			}
+		}
+		if len(want) == 0 {
+			want = nil
+		}
+		assert.Equal(t, want, satisfied, "satisfied")
+	}
+
+	t.Run("custom specification", func(t *testing.T) {
+		check(overdue, []int64{1, 4})
+		assert.True(t, overdue.IsSatisfiedBy(invoices[0]))
+		assert.False(t, overdue.IsSatisfiedBy(customers[0]))
+	})
+
+	t.Run("filter operators", func(t *testing.T) {
+		check(Gte("amount", 100), []int64{1, 3})
+		check(Eq("status", "open"), []int64{1, 3})
+		check(Contains("status", "OPE"), []int64{1, 3, 4})
+		check(In("customer_id", []int{2}), []int64{3, 4})
+		check(Between("due_at", today.AddDate(0, 0, -5), today), []int64{1, 2, 4})
+		check(InRange("due_at", today.AddDate(0, 0, -5), today.AddDate(0, 0, -1)), []int64{1, 2})
+		check(IsNotNull("paid_at"), []int64{2})
+		check(Eq("Customer.name", "Bob"), []int64{3, 4})
+		check(Or(Lt("amount", 60), And(Eq("customer_id", 1), NotStartsWith("status", "p"))), []int64{1, 4})
+	})
+
+	t.Run("null is unknown", func(t *testing.T) {
+		check(Lt("paid_at", today), []int64{2})
+		check(Not(Lt("paid_at", today)), []int64{})
+		check(Or(Not(Lt("paid_at", today)), Eq("invoice.id", 3)), []int64{3})
+	})
+
+	t.Run("combinators", func(t *testing.T) {
+		check(dbstore.And(overdue, Gte("amount", 100)), []int64{1})
+		check(dbstore.Or(overdue, Eq("Customer.name", "Bob")), []int64{1, 3, 4})
+		check(dbstore.Not(overdue), []int64{2, 3})
+		check(dbstore.And(dbstore.Not(Eq("status", "paid")), dbstore.Or(Gt("amount", 200), Eq("invoice.id", 1))), []int64{1, 3})
+	})
+
+	t.Run("in go only", func(t *testing.T) {
+		assert.True(t, Matches("status", "^O").IsSatisfiedBy(invoices[3]))
+		assert.False(t, Matches("status", "^O").IsSatisfiedBy(invoices[0]))
+		assert.True(t, MatchesFold("status", "^O").IsSatisfiedBy(invoices[0]))
+		assert.True(t, ArrayContainsAny("tags", []string{"vip", "x"}).IsSatisfiedBy(invoices[1]))
+		assert.True(t, ArrayContainsAll("tags", []string{"vip", "us"}).IsSatisfiedBy(invoices[1]))
+		assert.False(t, ArrayContainsAll("tags", []string{"vip", "eu"}).IsSatisfiedBy(invoices[1]))
+		assert.False(t, ArrayOverlaps("tags", []string{"eu"}).IsSatisfiedBy(invoices[2]))
+		assert.False(t, Not(ArrayOverlaps("tags", []string{"eu"})).IsSatisfiedBy(invoices[2]))
+	})
+
+	t.Run("cannot evaluate", func(t *testing.T) {
+		_, err := Evaluate(JSONEq("attrs", "a", 1), invoices[0])
+		assert.Error(t, err)
+		_, err = Evaluate(Eq("missing", 1), invoices[0])
+		assert.Error(t, err)
+		_, err = Evaluate(Eq("amount", "x"), invoices[0])
+		assert.Error(t, err)
+		assert.False(t, Eq("amount", "x").IsSatisfiedBy(invoices[0]))
+	})
+}
+
+func TestEvaluateManyToMany(t *testing.T) {
+	type Genre struct {
+		ID   int64 `bun:",pk"`
+		Name string
+	}
+	type Writer struct {
+		bun.BaseModel `bun:"table:writers"`
+		ID            int64 `bun:",pk"`
+		Name          string
+		Genres        []Genre `bun:"m2m:writer_genres,join:Writer=Genre"`
+	}
+	type WriterGenre struct {
+		bun.BaseModel `bun:"table:writer_genres"`
+		WriterID      int64   `bun:",pk"`
+		Writer        *Writer `bun:"rel:belongs-to,join:writer_id=id"`
+		GenreID       int64   `bun:",pk"`
+		Genre         *Genre  `bun:"rel:belongs-to,join:genre_id=id"`
+	}
+	type Shelf struct {
+		ID     int64   `bun:",pk"`
+		Genres []Genre `bun:"m2m:shelf_genres,join:Shelf=Genre"`
+	}
+
+	t.Run("unregistered join model", func(t *testing.T) {
+		_, err := Evaluate(Eq("id", 1), Shelf{ID: 1})
+		assert.ErrorContains(t, err, "shelf_genres")
+		assert.False(t, Eq("id", 1).IsSatisfiedBy(&Shelf{ID: 1}))
+
+		// the failure is remembered rather than using a half built table
+		_, err = Evaluate(Eq("id", 1), Shelf{ID: 1})
+		assert.ErrorContains(t, err, "shelf_genres")
+	})
+
+	t.Run("registered join model", func(t *testing.T) {
+		RegisterModel((*WriterGenre)(nil))
+
+		ok, err := Evaluate(Eq("name", "ann"), &Writer{ID: 1, Name: "ann"})
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.False(t, Eq("name", "ann").IsSatisfiedBy(Writer{ID: 2, Name: "bob"}))
+	})
+}
+
+func TestSkippedConditionsAreNil(t *testing.T) {
+	cond, err := NewCondition(OpContains, "title", "")
+	assert.NoError(t, err)
+	assert.True(t, cond == nil)
+
+	cond, err = NewCondition(OpEqual, "title", nil)
+	assert.NoError(t, err)
+	assert.True(t, cond == nil)
+
+	cond, err = ParseExpr(`title contains ""`, nil)
+	assert.NoError(t, err)
+	assert.True(t, cond == nil)
+
+	cond, err = ParseExpr(`not (title contains "" or title starts "")`, nil)
+	assert.NoError(t, err)
+	assert.True(t, cond == nil)
+
+	cond, err = DecodeJSON([]byte(`{"and": [{"field": "title", "op": "contains", "value": ""}]}`), nil)
+	assert.NoError(t, err)
+	assert.True(t, cond == nil)
+
+	p, err := NewQueryParser(newDB(t), User{}, Allowlist{"name": {OpContains}}, WithSearch("name"))
+	assert.NoError(t, err)
+	pq, err := p.Parse(url.Values{"name[contains]": {""}, "q": {" "}})
+	assert.NoError(t, err)
+	assert.Empty(t, pq.Conditions)
+
+	type BookFilter struct {
+		Title *string `filter:"title,op=contains"`
+	}
+	empty := ""
+	group, err := FromStruct(&BookFilter{Title: &empty})
+	assert.NoError(t, err)
+	assert.Empty(t, group.Conditions())
+}
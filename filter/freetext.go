@@ -0,0 +1,35 @@
+package filter
+
+import "strings"
+
+// FreeText matches rows where every word of a user query, e.g the "q"
+// parameter of a list endpoint, is contained in at least one of the
+// columns, ignoring case:
+//
+//	// (name LIKE %ann% OR email LIKE %ann%) AND (name LIKE %smith% OR email LIKE %smith%)
+//	filter.Where(q, filter.FreeText("ann smith", "name", "email"))
+//
+// The condition is one group, so it composes with other conditions as a
+// whole. A query without words is skipped.
+func FreeText(query string, columns ...string) *Group {
+	var (
+		words = strings.Fields(query)
+		seen  = make(map[string]bool, len(words))
+		conds = make([]Condition, 0, len(words))
+	)
+
+	for _, word := range words {
+		if seen[strings.ToLower(word)] {
+			continue
+		}
+		seen[strings.ToLower(word)] = true
+
+		matches := make([]Condition, len(columns))
+		for i, column := range columns {
+			matches[i] = Contains(column, word)
+		}
+		conds = append(conds, Or(matches...))
+	}
+
+	return And(conds...)
+}
@@ -0,0 +1,139 @@
+package filter
+
+import (
+	"reflect"
+
+	"github.com/uptrace/bun/schema"
+)
+
+// Condition is a filter that can be added to a query with Where and OrWhere.
+// It is implemented by the operators, e.g Eq or Contains, and by the And, Or
+// and Not combinators, which nest to any depth.
+type Condition interface {
+	schema.QueryAppender
+
+	isEmpty() bool
+	applyTo(q whereQuery, or bool)
+}
+
+// Group joins its conditions with "AND" or "OR" and is rendered in
+// parentheses through bun's WhereGroup.
+type Group struct {
+	or         bool
+	conditions []Condition
+}
+
+// Negation negates its condition with "NOT".
+type Negation struct {
+	condition Condition
+}
+
+// And groups conditions that must all be true. Skipped conditions,
+// e.g an Eq with a nil value, are left out of the group.
+func And(conds ...Condition) *Group {
+	return newGroup(false, conds)
+}
+
+// Or groups conditions of which at least one must be true. Skipped
+// conditions, e.g an Eq with a nil value, are left out of the group.
+func Or(conds ...Condition) *Group {
+	return newGroup(true, conds)
+}
+
+// Not negates a condition. Negating a skipped condition is skipped too.
+func Not(cond Condition) *Negation {
+	return &Negation{condition: cond}
+}
+
+// IsOr reports whether the group joins its conditions with "OR".
+func (g *Group) IsOr() bool {
+	return g.or
+}
+
+// Conditions returns the conditions of the group.
+func (g *Group) Conditions() []Condition {
+	return g.conditions
+}
+
+// Condition returns the negated condition.
+func (n *Negation) Condition() Condition {
+	return n.condition
+}
+
+func newGroup(or bool, conds []Condition) *Group {
+	g := &Group{or: or}
+	for _, cond := range conds {
+		if isEmpty(cond) {
+			continue
+		}
+		g.conditions = append(g.conditions, cond)
+	}
+	return g
+}
+
+func (g *Group) separator() string {
+	if g.or {
+		return " OR "
+	}
+	return " AND "
+}
+
+func (g *Group) isEmpty() bool {
+	return g == nil || len(g.conditions) == 0
+}
+
+func (g *Group) applyTo(q whereQuery, or bool) {
+	sep := " AND "
+	if or {
+		sep = " OR "
+	}
+
+	q.whereGroup(sep, func(q whereQuery) {
+		for _, cond := range g.conditions {
+			cond.applyTo(q, g.or)
+		}
+	})
+}
+
+func (g *Group) AppendQuery(fmter schema.Formatter, b []byte) (_ []byte, err error) {
+	b = append(b, '(')
+	for i, cond := range g.conditions {
+		if i > 0 {
+			b = append(b, g.separator()...)
+		}
+		b = append(b, '(')
+		if b, err = cond.AppendQuery(fmter, b); err != nil {
+			return nil, err
+		}
+		b = append(b, ')')
+	}
+	return append(b, ')'), nil
+}
+
+func (n *Negation) isEmpty() bool {
+	return n == nil || isEmpty(n.condition)
+}
+
+func (n *Negation) applyTo(q whereQuery, or bool) {
+	if or {
+		q.whereOr("NOT (?)", n.condition)
+		return
+	}
+	q.where("NOT (?)", n.condition)
+}
+
+func (n *Negation) AppendQuery(fmter schema.Formatter, b []byte) ([]byte, error) {
+	return fmter.AppendQuery(b, "NOT (?)", n.condition), nil
+}
+
+// isEmpty reports whether cond was skipped, which includes a typed nil
+// returned by an operator given an empty value.
+func isEmpty(cond Condition) bool {
+	if cond == nil {
+		return true
+	}
+	if v := reflect.ValueOf(cond); v.Kind() == reflect.Pointer && v.IsNil() {
+		return true
+	}
+	return cond.isEmpty()
+}
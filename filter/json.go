@@ -0,0 +1,253 @@
+package filter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// DecodeJSON decodes a condition from its JSON form, e.g
+//
+//	{"and": [
+//		{"field": "age", "op": "gte", "value": 18},
+//		{"not": {"field": "name", "op": "contains", "value": "bot"}}
+//	]}
+//
+// Nodes are either a group, {"and": [...]} or {"or": [...]}, a negation,
+// {"not": {...}}, or a condition on a field. When allow is not nil only its
+// columns and operators are accepted. An invalid node is reported as a
+// FieldError whose Param is the path to the node, e.g "and[1].not.op".
+// A node whose conditions are all skipped gives a nil Condition. Times are
+// written {"$time": "2024-03-10T00:00:00Z"}, so they decode as a time.Time.
+func DecodeJSON(data []byte, allow Allowlist) (Condition, error) {
+	cond, err := decodeJSONNode(data, allow, "")
+	if err != nil || isEmpty(cond) {
+		return nil, err
+	}
+	return cond, nil
+}
+
+// EncodeJSON encodes a condition to the JSON form read by DecodeJSON.
+func EncodeJSON(cond Condition) ([]byte, error) {
+	if isEmpty(cond) {
+		return []byte("null"), nil
+	}
+	if _, ok := cond.(json.Marshaler); !ok {
+		return nil, fmt.Errorf("condition %T has no JSON form", cond)
+	}
+	return json.Marshal(cond)
+}
+
+func (n *FieldCondition) MarshalJSON() ([]byte, error) {
+	if n.op == "" {
+		return nil, fmt.Errorf("condition on %q has no JSON form", n.columnName)
+	}
+	return json.Marshal(struct {
+		Field string   `json:"field"`
+		Op    Operator `json:"op"`
+		Value any      `json:"value"`
+	}{n.columnName, n.op, encodeJSONValue(n.value)})
+}
+
+// jsonTime is the JSON form of a time, which DecodeJSON reads back as a
+// time.Time rather than a string.
+type jsonTime struct {
+	Time string `json:"$time"`
+}
+
+// encodeJSONValue replaces the times of a value, or of its elements, with
+// their jsonTime form.
+func encodeJSONValue(value any) any {
+	switch v := value.(type) {
+	case time.Time:
+		return jsonTime{v.Format(time.RFC3339Nano)}
+	case []byte:
+		return v
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice || rv.IsNil() {
+		return value
+	}
+	list := make([]any, rv.Len())
+	for i := range list {
+		list[i] = encodeJSONValue(rv.Index(i).Interface())
+	}
+	return list
+}
+
+func (g *Group) MarshalJSON() ([]byte, error) {
+	conditions := make([]json.RawMessage, 0, len(g.conditions))
+	for _, cond := range g.conditions {
+		b, err := EncodeJSON(cond)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, b)
+	}
+
+	if g.or {
+		return json.Marshal(map[string]any{"or": conditions})
+	}
+	return json.Marshal(map[string]any{"and": conditions})
+}
+
+func (n *Negation) MarshalJSON() ([]byte, error) {
+	b, err := EncodeJSON(n.condition)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]any{"not": json.RawMessage(b)})
+}
+
+func decodeJSONNode(data []byte, allow Allowlist, path string) (Condition, error) {
+	fail := func(param string, format string, args ...any) error {
+		return &FieldError{Param: param, Message: fmt.Sprintf(format, args...)}
+	}
+	at := func(key string) string {
+		if path == "" {
+			return key
+		}
+		return path + "." + key
+	}
+
+	var node map[string]json.RawMessage
+	if err := json.Unmarshal(data, &node); err != nil || node == nil {
+		return nil, fail(pathOrRoot(path), "expected a JSON object")
+	}
+
+	keys := make([]string, 0, len(node))
+	for key := range node {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	switch {
+	case len(node) == 1 && (node["and"] != nil || node["or"] != nil):
+		key := keys[0]
+
+		var items []json.RawMessage
+		if err := json.Unmarshal(node[key], &items); err != nil {
+			return nil, fail(at(key), "expected a list of conditions")
+		}
+
+		conds := make([]Condition, 0, len(items))
+		for i, item := range items {
+			cond, err := decodeJSONNode(item, allow, fmt.Sprintf("%s[%d]", at(key), i))
+			if err != nil {
+				return nil, err
+			}
+			conds = append(conds, cond)
+		}
+
+		if key == "or" {
+			return Or(conds...), nil
+		}
+		return And(conds...), nil
+
+	case len(node) == 1 && node["not"] != nil:
+		cond, err := decodeJSONNode(node["not"], allow, at("not"))
+		if err != nil {
+			return nil, err
+		}
+		return Not(cond), nil
+
+	case node["field"] != nil:
+		for _, key := range keys {
+			if key != "field" && key != "op" && key != "value" {
+				return nil, fail(at(key), "unexpected key")
+			}
+		}
+
+		var (
+			field string
+			op    Operator
+		)
+		if err := json.Unmarshal(node["field"], &field); err != nil || field == "" {
+			return nil, fail(at("field"), "expected a column name")
+		}
+		if err := json.Unmarshal(node["op"], &op); err != nil || !op.IsValid() {
+			return nil, fail(at("op"), "expected a known operator")
+		}
+
+		if allow != nil {
+			allowed, ok := allow[field]
+			if !ok {
+				return nil, fail(at("field"), "cannot filter on %q", field)
+			}
+			if !containsOperator(allowed, op) {
+				return nil, fail(at("op"), "operator %q is not allowed on %q", op, field)
+			}
+		}
+
+		value, err := decodeJSONValue(node["value"])
+		if err != nil {
+			return nil, fail(at("value"), "%s", err)
+		}
+
+		cond, err := NewCondition(op, field, value)
+		if err != nil {
+			return nil, fail(at("value"), "%s", err)
+		}
+		return cond, nil
+	}
+
+	return nil, fail(pathOrRoot(path), `expected one of "and", "or", "not" or "field"`)
+}
+
+// decodeJSONValue decodes numbers as int64 when they are integral and
+// float64 otherwise, and times from their jsonTime form, so they encode
+// back as they were.
+func decodeJSONValue(data json.RawMessage) (any, error) {
+	if data == nil {
+		return nil, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	return normalizeJSONValue(value)
+}
+
+func normalizeJSONValue(value any) (any, error) {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+		f, _ := v.Float64()
+		return f, nil
+	case map[string]any:
+		s, ok := v["$time"].(string)
+		if len(v) != 1 || !ok {
+			return nil, fmt.Errorf(`expected a value or {"$time": "..."}`)
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time %q, expected RFC 3339", s)
+		}
+		return t, nil
+	case []any:
+		for i := range v {
+			var err error
+			if v[i], err = normalizeJSONValue(v[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return value, nil
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "$"
+	}
+	return path
+}
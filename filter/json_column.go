@@ -0,0 +1,247 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// The JSON operators filter on a path into a JSON column, e.g "size.width"
+// or "tags.0", where numbers index arrays. On Postgres they use the jsonb
+// operators ->, ->>, ? and @>, so the column must be jsonb, and on SQLite
+// the JSON1 functions json_extract, json_type and json_each. Values are
+// compared as JSON scalars: strings, numbers, booleans and null.
+
+// JSONEq matches a JSON column whose value at the path equals value.
+func JSONEq(columnName string, path string, value any) *FieldCondition {
+	return JSONCompare(columnName, path, OpEqual, value)
+}
+
+// JSONCompare compares the value at the path of a JSON column with value,
+// using a comparison operator, e.g OpGreaterThan. It panics on any other
+// operator.
+func JSONCompare(columnName string, path string, op Operator, value any) *FieldCondition {
+	cmp, ok := comparisons[op]
+	if !ok {
+		panic(fmt.Sprintf("operator %q is not a comparison", op))
+	}
+	if value == nil {
+		return nil
+	}
+
+	return newJSONStmt(OpJSONCompare, columnName, []any{path, op, value}, func(column bun.Ident) schema.QueryAppender {
+		return &jsonCompareExpr{column: column, path: splitJSONPath(path), cmp: cmp, value: value}
+	})
+}
+
+// JSONHasKey matches a JSON column having the path, whose last part is
+// a key, e.g "size.width" for {"size": {"width": null}}.
+func JSONHasKey(columnName string, path string) *FieldCondition {
+	if empty(path) {
+		return nil
+	}
+
+	return newJSONStmt(OpJSONHasKey, columnName, path, func(column bun.Ident) schema.QueryAppender {
+		return &jsonHasKeyExpr{column: column, path: splitJSONPath(path)}
+	})
+}
+
+// JSONContains matches a JSON column whose value at the path, or the whole
+// document when path is empty, contains doc, e.g {"size": {"width": 10}}
+// or ["red"]: objects contain the keys of doc and arrays its items. On
+// SQLite, arrays can only be matched on scalar items. Its Value is the path
+// and doc encoded to JSON text, and a json.RawMessage doc is used as it is.
+func JSONContains(columnName string, path string, doc any) *FieldCondition {
+	if doc == nil {
+		return nil
+	}
+
+	value := []any{path, doc}
+	if data, err := json.Marshal(doc); err == nil {
+		value[1] = string(data)
+	}
+	return newJSONStmt(OpJSONContains, columnName, value, func(column bun.Ident) schema.QueryAppender {
+		return &jsonContainsExpr{column: column, path: splitJSONPath(path), doc: doc}
+	})
+}
+
+// JSONArrayContains matches a JSON column whose array at the path, or the
+// whole document when path is empty, has the scalar value as an item.
+func JSONArrayContains(columnName string, path string, value any) *FieldCondition {
+	if value == nil {
+		return nil
+	}
+	return JSONContains(columnName, path, []any{value})
+}
+
+func newJSONStmt(op Operator, columnName string, value any, expr func(column bun.Ident) schema.QueryAppender) *FieldCondition {
+	return &FieldCondition{stmt: "?", columnName: columnName, expr: expr, op: op, value: value}
+}
+
+var comparisons = map[Operator]string{
+	OpEqual:              "=",
+	OpNotEqual:           "!=",
+	OpLessThan:           "<",
+	OpLessThanOrEqual:    "<=",
+	OpGreaterThan:        ">",
+	OpGreaterThanOrEqual: ">=",
+}
+
+func splitJSONPath(path string) []string {
+	if empty(path) {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+type jsonCompareExpr struct {
+	column bun.Ident
+	path   []string
+	cmp    string
+	value  any
+}
+
+func (e *jsonCompareExpr) AppendQuery(fmter schema.Formatter, b []byte) ([]byte, error) {
+	if fmter.Dialect().Name() != dialect.PG {
+		b = appendJSONExtract(fmter, b, e.column, e.path)
+		return fmter.AppendQuery(b, " "+e.cmp+" ?", e.value), nil
+	}
+
+	// ->> returns text, which is cast to compare numbers and booleans
+	cast, value := "", e.value
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Bool:
+		cast = "::boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		cast = "::numeric"
+	default:
+		value = fmt.Sprint(value)
+	}
+
+	b = append(b, '(')
+	b = appendJSONArrows(fmter, b, e.column, e.path, true)
+	return fmter.AppendQuery(b, ")"+cast+" "+e.cmp+" ?", value), nil
+}
+
+type jsonHasKeyExpr struct {
+	column bun.Ident
+	path   []string
+}
+
+func (e *jsonHasKeyExpr) AppendQuery(fmter schema.Formatter, b []byte) ([]byte, error) {
+	if fmter.Dialect().Name() != dialect.PG {
+		return fmter.AppendQuery(b, "json_type(?, ?) IS NOT NULL", e.column, sqliteJSONPath(e.path)), nil
+	}
+
+	last := len(e.path) - 1
+	b = appendJSONArrows(fmter, b, e.column, e.path[:last], false)
+	// the jsonb ? operator, escaped from bun's placeholders
+	return fmter.AppendQuery(b, ` \? ?`, e.path[last]), nil
+}
+
+type jsonContainsExpr struct {
+	column bun.Ident
+	path   []string
+	doc    any
+}
+
+func (e *jsonContainsExpr) AppendQuery(fmter schema.Formatter, b []byte) ([]byte, error) {
+	data, err := json.Marshal(e.doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if fmter.Dialect().Name() == dialect.PG {
+		b = appendJSONArrows(fmter, b, e.column, e.path, false)
+		return fmter.AppendQuery(b, " @> CAST(? AS jsonb)", string(data)), nil
+	}
+
+	// SQLite has no containment operator, so the document is matched value
+	// by value, after decoding it the way JSON1 reads it
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return appendSQLiteJSONContains(fmter, b, e.column, e.path, doc)
+}
+
+func appendSQLiteJSONContains(fmter schema.Formatter, b []byte, column bun.Ident, path []string, doc any) (_ []byte, err error) {
+	jsonPath := sqliteJSONPath(path)
+
+	switch doc := doc.(type) {
+	case map[string]any:
+		b = fmter.AppendQuery(b, "json_type(?, ?) = 'object'", column, jsonPath)
+
+		keys := make([]string, 0, len(doc))
+		for key := range doc {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			b = append(b, " AND "...)
+			if b, err = appendSQLiteJSONContains(fmter, b, column, append(path[:len(path):len(path)], key), doc[key]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case []any:
+		b = fmter.AppendQuery(b, "json_type(?, ?) = 'array'", column, jsonPath)
+		for _, item := range doc {
+			switch item.(type) {
+			case map[string]any, []any:
+				return nil, fmt.Errorf("sqlite: cannot match the array at %q on an object or array item", jsonPath)
+			}
+			b = fmter.AppendQuery(b, " AND EXISTS (SELECT 1 FROM json_each(?, ?) WHERE json_each.value = ?)", column, jsonPath, item)
+		}
+		return b, nil
+	case nil:
+		return fmter.AppendQuery(b, "json_type(?, ?) = 'null'", column, jsonPath), nil
+	}
+	return fmter.AppendQuery(b, "json_extract(?, ?) = ?", column, jsonPath, doc), nil
+}
+
+// appendJSONArrows appends the column followed by the path as Postgres
+// json operators, e.g "attrs"->'size'->>'width' when text is set.
+func appendJSONArrows(fmter schema.Formatter, b []byte, column bun.Ident, path []string, text bool) []byte {
+	b = fmter.AppendQuery(b, "?", column)
+	for i, key := range path {
+		arrow := "->"
+		if text && i == len(path)-1 {
+			arrow = "->>"
+		}
+
+		if index, err := strconv.Atoi(key); err == nil {
+			b = fmter.AppendQuery(b, arrow+"?", index)
+			continue
+		}
+		b = fmter.AppendQuery(b, arrow+"?", key)
+	}
+	return b
+}
+
+func appendJSONExtract(fmter schema.Formatter, b []byte, column bun.Ident, path []string) []byte {
+	return fmter.AppendQuery(b, "json_extract(?, ?)", column, sqliteJSONPath(path))
+}
+
+// sqliteJSONPath formats a path the way JSON1 reads it, e.g $."size"."max width"[0].
+func sqliteJSONPath(path []string) string {
+	p := "$"
+	for _, key := range path {
+		if _, err := strconv.Atoi(key); err == nil {
+			p += "[" + key + "]"
+			continue
+		}
+		p += "." + strconv.Quote(key)
+	}
+	return p
+}
@@ -0,0 +1,145 @@
+package filter
+
+import (
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// likeExpr matches a column against a value taken literally: the wildcards
+// of the value, e.g the "%" of "50%", are escaped. How the match is written
+// depends on the dialect:
+//
+//   - case-insensitive: ILIKE on Postgres, otherwise lower() on both sides
+//     of LIKE.
+//   - case-sensitive: LIKE on Postgres, otherwise GLOB, as LIKE ignores the
+//     case of ASCII letters on SQLite.
+type likeExpr struct {
+	column    bun.Ident
+	value     string
+	prefix    bool // anything may come before the value
+	suffix    bool // anything may come after the value
+	not       bool
+	sensitive bool
+}
+
+func (e *likeExpr) AppendQuery(fmter schema.Formatter, b []byte) ([]byte, error) {
+	not := ""
+	if e.not {
+		not = "NOT "
+	}
+
+	pg := fmter.Dialect().Name() == dialect.PG
+	switch {
+	case pg && e.sensitive:
+		return fmter.AppendQuery(b, "? "+not+`LIKE ? ESCAPE '\'`, e.column, e.pattern("%", escapeLike)), nil
+	case pg:
+		return fmter.AppendQuery(b, "? "+not+`ILIKE ? ESCAPE '\'`, e.column, e.pattern("%", escapeLike)), nil
+	case e.sensitive:
+		return fmter.AppendQuery(b, "? "+not+"GLOB ?", e.column, e.pattern("*", escapeGlob)), nil
+	}
+	return fmter.AppendQuery(b, "lower(?) "+not+`LIKE lower(?) ESCAPE '\'`, e.column, e.pattern("%", escapeLike)), nil
+}
+
+func (e *likeExpr) pattern(wildcard string, escape func(string) string) string {
+	p := escape(e.value)
+	if e.prefix {
+		p = wildcard + p
+	}
+	if e.suffix {
+		p += wildcard
+	}
+	return p
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// escapeLike escapes the LIKE wildcards with a backslash, the ESCAPE
+// character of the statements.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var globEscaper = strings.NewReplacer("*", "[*]", "?", "[?]", "[", "[[]")
+
+// escapeGlob escapes the GLOB wildcards, which have no escape character,
+// by putting them in a character class.
+func escapeGlob(s string) string {
+	return globEscaper.Replace(s)
+}
+
+func newLikeStmt(op Operator, columnName string, value string, e *likeExpr) *FieldCondition {
+	if empty(value) {
+		return nil
+	}
+
+	e.value = value
+	return &FieldCondition{
+		stmt:       "?",
+		columnName: columnName,
+		expr: func(column bun.Ident) schema.QueryAppender {
+			e := *e
+			e.column = column
+			return &e
+		},
+		op:    op,
+		value: value,
+	}
+}
+
+// Contains matches a column containing the value, ignoring case.
+// Wildcards in the value, e.g "%" or "_", are matched literally.
+func Contains(columnName string, value string) *FieldCondition {
+	return newLikeStmt(OpContains, columnName, value, &likeExpr{prefix: true, suffix: true})
+}
+
+func NotContains(columnName string, value string) *FieldCondition {
+	return newLikeStmt(OpNotContains, columnName, value, &likeExpr{prefix: true, suffix: true, not: true})
+}
+
+// StartsWith matches a column starting with the value, ignoring case.
+func StartsWith(columnName string, value string) *FieldCondition {
+	return newLikeStmt(OpStartsWith, columnName, value, &likeExpr{suffix: true})
+}
+
+func NotStartsWith(columnName string, value string) *FieldCondition {
+	return newLikeStmt(OpNotStartsWith, columnName, value, &likeExpr{suffix: true, not: true})
+}
+
+// EndsWith matches a column ending with the value, ignoring case.
+func EndsWith(columnName string, value string) *FieldCondition {
+	return newLikeStmt(OpEndsWith, columnName, value, &likeExpr{prefix: true})
+}
+
+func NotEndsWith(columnName string, value string) *FieldCondition {
+	return newLikeStmt(OpNotEndsWith, columnName, value, &likeExpr{prefix: true, not: true})
+}
+
+// ContainsCase is Contains, matching case.
+func ContainsCase(columnName string, value string) *FieldCondition {
+	return newLikeStmt(OpContainsCase, columnName, value, &likeExpr{prefix: true, suffix: true, sensitive: true})
+}
+
+func NotContainsCase(columnName string, value string) *FieldCondition {
+	return newLikeStmt(OpNotContainsCase, columnName, value, &likeExpr{prefix: true, suffix: true, not: true, sensitive: true})
+}
+
+// StartsWithCase is StartsWith, matching case.
+func StartsWithCase(columnName string, value string) *FieldCondition {
+	return newLikeStmt(OpStartsWithCase, columnName, value, &likeExpr{suffix: true, sensitive: true})
+}
+
+func NotStartsWithCase(columnName string, value string) *FieldCondition {
+	return newLikeStmt(OpNotStartsWithCase, columnName, value, &likeExpr{suffix: true, not: true, sensitive: true})
+}
+
+// EndsWithCase is EndsWith, matching case.
+func EndsWithCase(columnName string, value string) *FieldCondition {
+	return newLikeStmt(OpEndsWithCase, columnName, value, &likeExpr{prefix: true, sensitive: true})
+}
+
+func NotEndsWithCase(columnName string, value string) *FieldCondition {
+	return newLikeStmt(OpNotEndsWithCase, columnName, value, &likeExpr{prefix: true, not: true, sensitive: true})
+}
@@ -1,6 +1,15 @@
 package filter
 
-import "strings"
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
 
 // shortcut alias. i.e alternative to longer names
 var (
@@ -16,21 +25,53 @@ var (
 	NotEnds   = NotEndsWith
 )
 
-type sqlWhere struct {
+// FieldCondition is a condition on one column, as built by the operators,
+// e.g Eq or Contains. Its Field, Op and Value are what it was built from,
+// and String formats it the way ParseExpr reads it.
+type FieldCondition struct {
 	stmt                string
 	columnName          string
 	columnValue         any
 	sql_IsNullQueryType bool
+
+	// expr, when set, renders the statement for the column, which is then
+	// "?", e.g for statements that depend on the dialect.
+	expr func(column bun.Ident) schema.QueryAppender
+
+	// op and value are the operator and the value as given, e.g "bo"
+	// rather than the "%bo%" passed to the query, for encoding.
+	op    Operator
+	value any
 }
 
-func newSqlWhereStmt(skipStatement bool, stmt string, columnName string, columnValue any) *sqlWhere {
+// Field returns the column of the condition.
+func (n *FieldCondition) Field() string {
+	return n.columnName
+}
+
+// Op returns the operator of the condition.
+func (n *FieldCondition) Op() Operator {
+	return n.op
+}
+
+// Value returns the value the condition was built with, e.g "bo" for
+// Contains("name", "bo"), true for IsNull, a list of the two bounds for
+// the range operators and []any{"size.width", OpGreaterThan, 20} for
+// JSONCompare("attrs", "size.width", OpGreaterThan, 20).
+func (n *FieldCondition) Value() any {
+	return n.value
+}
+
+func newSqlWhereStmt(skipStatement bool, op Operator, stmt string, columnName string, value any, columnValue any) *FieldCondition {
 	if skipStatement {
 		return nil
 	}
-	return &sqlWhere{
+	return &FieldCondition{
 		stmt:        stmt,
 		columnName:  columnName,
 		columnValue: columnValue,
+		op:          op,
+		value:       value,
 	}
 }
 
@@ -38,74 +79,348 @@ func empty(s string) bool {
 	return strings.TrimSpace(s) == ""
 }
 
-func (n *sqlWhere) isANullQueryType() bool {
+func (n *FieldCondition) isANullQueryType() bool {
 	return n.sql_IsNullQueryType
 }
 
-func Equal(columnName string, value any) *sqlWhere {
-	return newSqlWhereStmt(value == nil, "? = ?", columnName, value)
-}
-
-func NotEqual(columnName string, value any) *sqlWhere {
-	return newSqlWhereStmt(value == nil, "? != ?", columnName, value)
-}
-
-func LessThan(columnName string, value any) *sqlWhere {
-	return newSqlWhereStmt(value == nil, "? < ?", columnName, value)
+func (n *FieldCondition) args() []any {
+	if n.expr != nil {
+		return []any{n.expr(bun.Ident(n.columnName))}
+	}
+	if n.isANullQueryType() {
+		return []any{bun.Ident(n.columnName)}
+	}
+	return []any{bun.Ident(n.columnName), n.columnValue}
 }
 
-func LessThanOrEqual(columnName string, value any) *sqlWhere {
-	return newSqlWhereStmt(value == nil, "? <= ?", columnName, value)
+func (n *FieldCondition) isEmpty() bool {
+	return n == nil
 }
 
-func GreaterThan(columnName string, value any) *sqlWhere {
-	return newSqlWhereStmt(value == nil, "? > ?", columnName, value)
+func (n *FieldCondition) applyTo(q whereQuery, or bool) {
+	if or {
+		q.whereOr(n.stmt, n.args()...)
+		return
+	}
+	q.where(n.stmt, n.args()...)
 }
 
-func GreaterThanOrEqual(columnName string, value any) *sqlWhere {
-	return newSqlWhereStmt(value == nil, "? >= ?", columnName, value)
+func (n *FieldCondition) AppendQuery(fmter schema.Formatter, b []byte) ([]byte, error) {
+	return fmter.AppendQuery(b, n.stmt, n.args()...), nil
 }
 
-func Contains(columnName string, value string) *sqlWhere {
-	return newSqlWhereStmt(empty(value), "lower(?) LIKE ?", columnName, "%"+value+"%")
+func Equal(columnName string, value any) *FieldCondition {
+	return newSqlWhereStmt(value == nil, OpEqual, "? = ?", columnName, value, value)
 }
 
-func NotContains(columnName string, value string) *sqlWhere {
-	return newSqlWhereStmt(empty(value), "lower(?) NOT LIKE ?", columnName, "%"+value+"%")
+func NotEqual(columnName string, value any) *FieldCondition {
+	return newSqlWhereStmt(value == nil, OpNotEqual, "? != ?", columnName, value, value)
 }
 
-func StartsWith(columnName string, value string) *sqlWhere {
-	return newSqlWhereStmt(empty(value), "lower(?) LIKE ?", columnName, value+"%")
+func LessThan(columnName string, value any) *FieldCondition {
+	return newSqlWhereStmt(value == nil, OpLessThan, "? < ?", columnName, value, value)
 }
 
-func NotStartsWith(columnName string, value string) *sqlWhere {
-	return newSqlWhereStmt(empty(value), "lower(?) NOT LIKE ?", columnName, value+"%")
+func LessThanOrEqual(columnName string, value any) *FieldCondition {
+	return newSqlWhereStmt(value == nil, OpLessThanOrEqual, "? <= ?", columnName, value, value)
 }
 
-func EndsWith(columnName string, value string) *sqlWhere {
-	return newSqlWhereStmt(empty(value), "lower(?) LIKE ?", columnName, "%"+value)
+func GreaterThan(columnName string, value any) *FieldCondition {
+	return newSqlWhereStmt(value == nil, OpGreaterThan, "? > ?", columnName, value, value)
 }
 
-func NotEndsWith(columnName string, value string) *sqlWhere {
-	return newSqlWhereStmt(empty(value), "lower(?) NOT LIKE ?", columnName, "%"+value)
+func GreaterThanOrEqual(columnName string, value any) *FieldCondition {
+	return newSqlWhereStmt(value == nil, OpGreaterThanOrEqual, "? >= ?", columnName, value, value)
 }
 
-func In[T any](columnName string, value []T) *sqlWhere {
-	return newSqlWhereStmt(len(value) == 0, "? IN (?)", columnName, value)
+func In[T any](columnName string, value []T) *FieldCondition {
+	return newSqlWhereStmt(len(value) == 0, OpIn, "? IN (?)", columnName, value, bun.In(value))
 }
 
-func NotIn[T any](columnName string, value []T) *sqlWhere {
-	return newSqlWhereStmt(len(value) == 0, "? NOT IN (?)", columnName, value)
+func NotIn[T any](columnName string, value []T) *FieldCondition {
+	return newSqlWhereStmt(len(value) == 0, OpNotIn, "? NOT IN (?)", columnName, value, bun.In(value))
 }
 
-func IsNull(columnName string) *sqlWhere {
-	q := newSqlWhereStmt(false, "? IS NULL", columnName, nil)
+func IsNull(columnName string) *FieldCondition {
+	q := newSqlWhereStmt(false, OpIsNull, "? IS NULL", columnName, true, nil)
 	q.sql_IsNullQueryType = true
 	return q
 }
 
-func IsNotNull(columnName string) *sqlWhere {
-	q := newSqlWhereStmt(false, "? IS NOT NULL", columnName, nil)
+func IsNotNull(columnName string) *FieldCondition {
+	q := newSqlWhereStmt(false, OpIsNull, "? IS NOT NULL", columnName, false, nil)
 	q.sql_IsNullQueryType = true
 	return q
 }
+
+// Operator names an operator outside of Go code, e.g in query strings
+// such as "age[gte]=18".
+type Operator string
+
+const (
+	OpEqual              Operator = "eq"
+	OpNotEqual           Operator = "neq"
+	OpLessThan           Operator = "lt"
+	OpLessThanOrEqual    Operator = "lte"
+	OpGreaterThan        Operator = "gt"
+	OpGreaterThanOrEqual Operator = "gte"
+	OpContains           Operator = "contains"
+	OpNotContains        Operator = "ncontains"
+	OpStartsWith         Operator = "starts"
+	OpNotStartsWith      Operator = "nstarts"
+	OpEndsWith           Operator = "ends"
+	OpNotEndsWith        Operator = "nends"
+	OpIn                 Operator = "in"
+	OpNotIn              Operator = "nin"
+	OpIsNull             Operator = "null" // true for IS NULL, false for IS NOT NULL
+
+	// case-sensitive variants of the string operators
+	OpContainsCase      Operator = "containscs"
+	OpNotContainsCase   Operator = "ncontainscs"
+	OpStartsWithCase    Operator = "startscs"
+	OpNotStartsWithCase Operator = "nstartscs"
+	OpEndsWithCase      Operator = "endscs"
+	OpNotEndsWithCase   Operator = "nendscs"
+
+	// regular expression operators, see Matches
+	OpMatches        Operator = "match"
+	OpNotMatches     Operator = "nmatch"
+	OpMatchesFold    Operator = "imatch"
+	OpNotMatchesFold Operator = "nimatch"
+
+	// range operators, taking a list of two bounds of which one may be nil
+	OpBetween    Operator = "between"
+	OpNotBetween Operator = "nbetween"
+	OpInRange    Operator = "range" // half-open, see InRange
+
+	// array operators, taking a list
+	OpArrayOverlaps    Operator = "overlaps"
+	OpArrayContainsAny Operator = "hasany"
+	OpArrayContainsAll Operator = "hasall"
+	OpArrayLength      Operator = "len" // a list of a comparison operator and a length, see ArrayLength
+
+	// JSON operators, see JSONCompare
+	OpJSONCompare  Operator = "json"         // a list of a path, a comparison operator and a value
+	OpJSONHasKey   Operator = "jsonhaskey"   // a path
+	OpJSONContains Operator = "jsoncontains" // a list of a path and a JSON document
+)
+
+// IsValid checks if the operator is known.
+func (op Operator) IsValid() bool {
+	switch op {
+	case OpEqual, OpNotEqual, OpLessThan, OpLessThanOrEqual, OpGreaterThan, OpGreaterThanOrEqual,
+		OpContains, OpNotContains, OpStartsWith, OpNotStartsWith, OpEndsWith, OpNotEndsWith,
+		OpContainsCase, OpNotContainsCase, OpStartsWithCase, OpNotStartsWithCase, OpEndsWithCase, OpNotEndsWithCase,
+		OpMatches, OpNotMatches, OpMatchesFold, OpNotMatchesFold, OpBetween, OpNotBetween, OpInRange, OpArrayOverlaps, OpArrayContainsAny, OpArrayContainsAll,
+		OpArrayLength, OpJSONCompare, OpJSONHasKey, OpJSONContains, OpIn, OpNotIn, OpIsNull:
+		return true
+	}
+	return false
+}
+
+// NewCondition builds the condition of the named operator. String operators
+// expect a string value, OpIn, OpNotIn and the array operators a slice, the
+// range operators a slice of two bounds and OpIsNull a bool. OpArrayLength,
+// OpJSONCompare, OpJSONHasKey and OpJSONContains expect the Value of the
+// condition they build, see their constants. A condition
+// skipped for its value, e.g Contains with an empty string, is returned as
+// a nil Condition.
+func NewCondition(op Operator, columnName string, value any) (Condition, error) {
+	cond, err := newCondition(op, columnName, value)
+	if err != nil || isEmpty(cond) {
+		return nil, err
+	}
+	return cond, nil
+}
+
+func newCondition(op Operator, columnName string, value any) (Condition, error) {
+	switch op {
+	case OpEqual:
+		return Equal(columnName, value), nil
+	case OpNotEqual:
+		return NotEqual(columnName, value), nil
+	case OpLessThan:
+		return LessThan(columnName, value), nil
+	case OpLessThanOrEqual:
+		return LessThanOrEqual(columnName, value), nil
+	case OpGreaterThan:
+		return GreaterThan(columnName, value), nil
+	case OpGreaterThanOrEqual:
+		return GreaterThanOrEqual(columnName, value), nil
+	case OpContains, OpNotContains, OpStartsWith, OpNotStartsWith, OpEndsWith, OpNotEndsWith,
+		OpContainsCase, OpNotContainsCase, OpStartsWithCase, OpNotStartsWithCase, OpEndsWithCase, OpNotEndsWithCase:
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("operator %q expects a string value, got %T", op, value)
+		}
+		return stringCondition(op, columnName, str), nil
+	case OpMatches, OpNotMatches, OpMatchesFold, OpNotMatchesFold:
+		pattern, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("operator %q expects a string value, got %T", op, value)
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("operator %q: %w", op, err)
+		}
+		switch op {
+		case OpMatches:
+			return Matches(columnName, pattern), nil
+		case OpNotMatches:
+			return NotMatches(columnName, pattern), nil
+		case OpMatchesFold:
+			return MatchesFold(columnName, pattern), nil
+		}
+		return NotMatchesFold(columnName, pattern), nil
+	case OpBetween, OpNotBetween, OpInRange:
+		v := reflect.ValueOf(value)
+		if v.Kind() != reflect.Slice || v.Len() != 2 {
+			return nil, fmt.Errorf("operator %q expects a list of two values, got %v", op, value)
+		}
+		from, to := v.Index(0).Interface(), v.Index(1).Interface()
+		switch op {
+		case OpBetween:
+			return Between(columnName, from, to), nil
+		case OpNotBetween:
+			return NotBetween(columnName, from, to), nil
+		}
+		return InRange(columnName, from, to), nil
+	case OpIn, OpNotIn, OpArrayOverlaps, OpArrayContainsAny, OpArrayContainsAll:
+		v := reflect.ValueOf(value)
+		if v.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("operator %q expects a list value, got %T", op, value)
+		}
+		list := make([]any, v.Len())
+		for i := range list {
+			list[i] = v.Index(i).Interface()
+		}
+		switch op {
+		case OpIn:
+			return In(columnName, list), nil
+		case OpNotIn:
+			return NotIn(columnName, list), nil
+		case OpArrayOverlaps:
+			return ArrayOverlaps(columnName, list), nil
+		case OpArrayContainsAny:
+			return ArrayContainsAny(columnName, list), nil
+		}
+		return ArrayContainsAll(columnName, list), nil
+	case OpArrayLength:
+		args, ok := conditionArgs(value, 2)
+		cmp, cmpOk := comparisonArg(args, 0)
+		n, nOk := intArg(args, 1)
+		if !ok || !cmpOk || !nOk {
+			return nil, fmt.Errorf("operator %q expects a list of a comparison and a length, got %v", op, value)
+		}
+		return ArrayLength(columnName, cmp, n), nil
+	case OpJSONCompare:
+		args, ok := conditionArgs(value, 3)
+		path, pathOk := stringArg(args, 0)
+		cmp, cmpOk := comparisonArg(args, 1)
+		if !ok || !pathOk || !cmpOk {
+			return nil, fmt.Errorf("operator %q expects a list of a path, a comparison and a value, got %v", op, value)
+		}
+		return JSONCompare(columnName, path, cmp, args[2]), nil
+	case OpJSONHasKey:
+		path, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("operator %q expects a string value, got %T", op, value)
+		}
+		return JSONHasKey(columnName, path), nil
+	case OpJSONContains:
+		args, ok := conditionArgs(value, 2)
+		path, pathOk := stringArg(args, 0)
+		doc, docOk := stringArg(args, 1)
+		if !ok || !pathOk || !docOk || !json.Valid([]byte(doc)) {
+			return nil, fmt.Errorf("operator %q expects a list of a path and a JSON document, got %v", op, value)
+		}
+		return JSONContains(columnName, path, json.RawMessage(doc)), nil
+	case OpIsNull:
+		isNull, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("operator %q expects a bool value, got %T", op, value)
+		}
+		if isNull {
+			return IsNull(columnName), nil
+		}
+		return IsNotNull(columnName), nil
+	}
+	return nil, fmt.Errorf("unknown operator %q", op)
+}
+
+// conditionArgs returns the n items of a list value.
+func conditionArgs(value any, n int) ([]any, bool) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice || v.Len() != n {
+		return nil, false
+	}
+	args := make([]any, n)
+	for i := range args {
+		args[i] = v.Index(i).Interface()
+	}
+	return args, true
+}
+
+func stringArg(args []any, i int) (string, bool) {
+	if i >= len(args) {
+		return "", false
+	}
+	s, ok := args[i].(string)
+	return s, ok
+}
+
+// comparisonArg reads a comparison operator, given as an Operator or by
+// its name.
+func comparisonArg(args []any, i int) (Operator, bool) {
+	if i >= len(args) {
+		return "", false
+	}
+	v := reflect.ValueOf(args[i])
+	if v.Kind() != reflect.String {
+		return "", false
+	}
+	op := Operator(v.String())
+	_, ok := comparisons[op]
+	return op, ok
+}
+
+func intArg(args []any, i int) (int, bool) {
+	if i >= len(args) {
+		return 0, false
+	}
+	switch v := reflect.ValueOf(args[i]); v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(v.Uint()), true
+	}
+	return 0, false
+}
+
+func stringCondition(op Operator, columnName string, value string) *FieldCondition {
+	switch op {
+	case OpContains:
+		return Contains(columnName, value)
+	case OpNotContains:
+		return NotContains(columnName, value)
+	case OpStartsWith:
+		return StartsWith(columnName, value)
+	case OpNotStartsWith:
+		return NotStartsWith(columnName, value)
+	case OpEndsWith:
+		return EndsWith(columnName, value)
+	case OpNotEndsWith:
+		return NotEndsWith(columnName, value)
+	case OpContainsCase:
+		return ContainsCase(columnName, value)
+	case OpNotContainsCase:
+		return NotContainsCase(columnName, value)
+	case OpStartsWithCase:
+		return StartsWithCase(columnName, value)
+	case OpNotStartsWithCase:
+		return NotStartsWithCase(columnName, value)
+	case OpEndsWithCase:
+		return EndsWithCase(columnName, value)
+	default:
+		return NotEndsWithCase(columnName, value)
+	}
+}
@@ -0,0 +1,348 @@
+package filter
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// Allowlist maps the columns a client may filter on to the operators
+// allowed on each of them.
+type Allowlist map[string][]Operator
+
+// QueryParser turns url query values, e.g
+// "?age[gte]=18&name[contains]=bo&sort=-created_at&limit=20", into the
+// conditions, sort and limit of a model, accepting only allowlisted columns
+// and operators. A parameter without an operator, e.g "age=18", means eq.
+// The sort parameter is read with ParseSort.
+type QueryParser struct {
+	table    *schema.Table
+	allow    Allowlist
+	sortable map[string]bool
+	maxLimit int
+	search   []string
+}
+
+type QueryParserOption func(p *QueryParser)
+
+// WithSortable sets the columns that can be sorted on.
+// By default they are the allowlisted columns.
+func WithSortable(columns ...string) QueryParserOption {
+	return func(p *QueryParser) {
+		p.sortable = make(map[string]bool, len(columns))
+		for _, column := range columns {
+			p.sortable[column] = true
+		}
+	}
+}
+
+// WithSearch makes the "q" parameter a FreeText search on the columns.
+func WithSearch(columns ...string) QueryParserOption {
+	return func(p *QueryParser) {
+		p.search = columns
+	}
+}
+
+// WithMaxLimit caps the limit a client can ask for. It is also the limit
+// when none, or 0, is asked for, so every query is capped.
+func WithMaxLimit(limit int) QueryParserOption {
+	return func(p *QueryParser) {
+		p.maxLimit = limit
+	}
+}
+
+// NewQueryParser creates a parser for the model, whose field types the
+// values are coerced to. It fails when an allowlisted or sortable column
+// is not a column of the model.
+func NewQueryParser(db bun.IDB, model any, allow Allowlist, opts ...QueryParserOption) (*QueryParser, error) {
+	p := &QueryParser{
+		table: db.Dialect().Tables().Get(reflect.TypeOf(model)),
+		allow: allow,
+	}
+
+	p.sortable = make(map[string]bool, len(allow))
+	for column := range allow {
+		p.sortable[column] = true
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	for column := range allow {
+		if !p.table.HasField(column) {
+			return nil, fmt.Errorf("allowlisted column %q is not a column of %s", column, p.table.TypeName)
+		}
+	}
+	for column := range p.sortable {
+		if !p.table.HasField(column) {
+			return nil, fmt.Errorf("sortable column %q is not a column of %s", column, p.table.TypeName)
+		}
+	}
+	for _, column := range p.search {
+		if !p.table.HasField(column) {
+			return nil, fmt.Errorf("search column %q is not a column of %s", column, p.table.TypeName)
+		}
+	}
+
+	return p, nil
+}
+
+// ParsedQuery holds what was parsed from url query values.
+type ParsedQuery struct {
+	Conditions []Condition
+	Sort       SortSpec
+	Limit      int
+}
+
+// Apply adds the conditions, sort and limit to the query.
+func (pq *ParsedQuery) Apply(q *bun.SelectQuery) {
+	for _, cond := range pq.Conditions {
+		Where(q, cond)
+	}
+	pq.Sort.Apply(q)
+	if pq.Limit > 0 {
+		Limit(q, pq.Limit)
+	}
+}
+
+// FieldError describes an invalid query parameter.
+type FieldError struct {
+	Param   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Param, e.Message)
+}
+
+// ValidationErrors lists every invalid query parameter.
+type ValidationErrors []*FieldError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Parse parses the query values. Parameters that are neither "sort", "limit",
+// "q" with WithSearch, nor an allowlisted column, e.g "page", are left for
+// the caller. Invalid parameters are reported together as ValidationErrors.
+func (p *QueryParser) Parse(values url.Values) (*ParsedQuery, error) {
+	var (
+		pq   = &ParsedQuery{}
+		errs ValidationErrors
+	)
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, value := range values[key] {
+			var err *FieldError
+
+			switch {
+			case key == "sort":
+				err = p.parseSort(pq, value)
+			case key == "limit":
+				err = p.parseLimit(pq, value)
+			case key == "q" && len(p.search) > 0:
+				if cond := FreeText(value, p.search...); !isEmpty(cond) {
+					pq.Conditions = append(pq.Conditions, cond)
+				}
+			default:
+				err = p.parseCondition(pq, key, value)
+			}
+
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if pq.Limit == 0 {
+		pq.Limit = p.maxLimit
+	}
+	return pq, nil
+}
+
+func (p *QueryParser) parseSort(pq *ParsedQuery, value string) *FieldError {
+	sortable := make([]string, 0, len(p.sortable))
+	for column := range p.sortable {
+		sortable = append(sortable, column)
+	}
+
+	spec, err := ParseSort(value, sortable)
+	if err != nil {
+		return err.(*FieldError)
+	}
+	pq.Sort = append(pq.Sort, spec...)
+	return nil
+}
+
+func (p *QueryParser) parseLimit(pq *ParsedQuery, value string) *FieldError {
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return &FieldError{Param: "limit", Message: "must be a non-negative integer"}
+	}
+	if p.maxLimit > 0 && (limit == 0 || limit > p.maxLimit) {
+		limit = p.maxLimit
+	}
+	pq.Limit = limit
+	return nil
+}
+
+func (p *QueryParser) parseCondition(pq *ParsedQuery, key string, value string) *FieldError {
+	column, op, bracketed := strings.Cut(key, "[")
+	if bracketed {
+		if !strings.HasSuffix(op, "]") {
+			return &FieldError{Param: key, Message: "malformed parameter, expected column[operator]"}
+		}
+		op = strings.TrimSuffix(op, "]")
+	} else {
+		op = string(OpEqual)
+	}
+
+	allowed, ok := p.allow[column]
+	if !ok {
+		if bracketed {
+			return &FieldError{Param: key, Message: fmt.Sprintf("cannot filter on %q", column)}
+		}
+		return nil
+	}
+
+	if !containsOperator(allowed, Operator(op)) {
+		return &FieldError{Param: key, Message: fmt.Sprintf("operator %q is not allowed on %q", op, column)}
+	}
+
+	typed, err := coerceValue(p.table.FieldMap[column], Operator(op), value)
+	if err != nil {
+		return &FieldError{Param: key, Message: err.Error()}
+	}
+
+	cond, err := NewCondition(Operator(op), column, typed)
+	if err != nil {
+		return &FieldError{Param: key, Message: err.Error()}
+	}
+	if cond != nil {
+		pq.Conditions = append(pq.Conditions, cond)
+	}
+	return nil
+}
+
+func containsOperator(ops []Operator, op Operator) bool {
+	for i := range ops {
+		if ops[i] == op {
+			return true
+		}
+	}
+	return false
+}
+
+// coerceValue converts the raw value to what the operator expects,
+// using the field type of the column.
+func coerceValue(field *schema.Field, op Operator, raw string) (any, error) {
+	switch op {
+	case OpContains, OpNotContains, OpStartsWith, OpNotStartsWith, OpEndsWith, OpNotEndsWith,
+		OpContainsCase, OpNotContainsCase, OpStartsWithCase, OpNotStartsWithCase, OpEndsWithCase, OpNotEndsWithCase,
+		OpMatches, OpNotMatches, OpMatchesFold, OpNotMatchesFold:
+		return raw, nil
+	case OpIsNull:
+		isNull, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", raw)
+		}
+		return isNull, nil
+	case OpIn, OpNotIn, OpArrayOverlaps, OpArrayContainsAny, OpArrayContainsAll:
+		typ := field.IndirectType
+		if op != OpIn && op != OpNotIn && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) {
+			typ = typ.Elem()
+		}
+
+		parts := strings.Split(raw, ",")
+		list := make([]any, len(parts))
+		for i := range parts {
+			v, err := coerce(typ, strings.TrimSpace(parts[i]))
+			if err != nil {
+				return nil, err
+			}
+			list[i] = v
+		}
+		return list, nil
+	case OpBetween, OpNotBetween, OpInRange:
+		parts := strings.Split(raw, ",")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%q is not a range, expected from,to", raw)
+		}
+		bounds := make([]any, 2)
+		for i := range parts {
+			if part := strings.TrimSpace(parts[i]); part != "" {
+				v, err := coerce(field.IndirectType, part)
+				if err != nil {
+					return nil, err
+				}
+				bounds[i] = v
+			}
+		}
+		return bounds, nil
+	}
+	return coerce(field.IndirectType, raw)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func coerce(typ reflect.Type, raw string) (any, error) {
+	if typ == timeType {
+		for _, layout := range []string{time.RFC3339Nano, time.DateTime, time.DateOnly} {
+			if t, err := time.Parse(layout, raw); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not a valid time", raw)
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(raw).Convert(typ).Interface(), nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", raw)
+		}
+		return reflect.ValueOf(b).Convert(typ).Interface(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, typ.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a valid %s", raw, typ.Kind())
+		}
+		return reflect.ValueOf(n).Convert(typ).Interface(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, typ.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a valid %s", raw, typ.Kind())
+		}
+		return reflect.ValueOf(n).Convert(typ).Interface(), nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, typ.Bits())
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a valid number", raw)
+		}
+		return reflect.ValueOf(f).Convert(typ).Interface(), nil
+	}
+	return nil, fmt.Errorf("cannot filter on a column of type %s", typ)
+}
@@ -0,0 +1,132 @@
+package filter
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// now is replaced in tests.
+var now = time.Now
+
+// queryExpr is a statement with its own arguments, for conditions on a
+// column that take more than one value.
+type queryExpr struct {
+	query string
+	args  []any
+}
+
+func (e *queryExpr) AppendQuery(fmter schema.Formatter, b []byte) ([]byte, error) {
+	return fmter.AppendQuery(b, e.query, e.args...), nil
+}
+
+func newRangeStmt(op Operator, query string, columnName string, from, to any) *FieldCondition {
+	return &FieldCondition{
+		stmt:       "?",
+		columnName: columnName,
+		expr: func(column bun.Ident) schema.QueryAppender {
+			return &queryExpr{query: query, args: []any{column, from, to}}
+		},
+		op:    op,
+		value: []any{from, to},
+	}
+}
+
+// Between matches a column from "from" to "to", both included. A nil bound,
+// or a nil pointer, leaves the range open on that side, e.g
+// Between(col, nil, to) is Lte(col, to), and two nil bounds skip the
+// condition. Times are compared in UTC.
+func Between(columnName string, from, to any) *FieldCondition {
+	from, to = utc(from), utc(to)
+	switch {
+	case from == nil:
+		return LessThanOrEqual(columnName, to)
+	case to == nil:
+		return GreaterThanOrEqual(columnName, from)
+	}
+	return newRangeStmt(OpBetween, "?0 BETWEEN ?1 AND ?2", columnName, from, to)
+}
+
+// NotBetween matches a column outside of Between(columnName, from, to).
+func NotBetween(columnName string, from, to any) *FieldCondition {
+	from, to = utc(from), utc(to)
+	switch {
+	case from == nil:
+		return GreaterThan(columnName, to)
+	case to == nil:
+		return LessThan(columnName, from)
+	}
+	return newRangeStmt(OpNotBetween, "?0 NOT BETWEEN ?1 AND ?2", columnName, from, to)
+}
+
+// InRange matches a column in the half-open range [from, to): "from" is
+// included and "to" is not, so consecutive ranges, e.g days, do not
+// overlap. Nil bounds are handled as with Between.
+func InRange(columnName string, from, to any) *FieldCondition {
+	from, to = utc(from), utc(to)
+	switch {
+	case from == nil:
+		return LessThan(columnName, to)
+	case to == nil:
+		return GreaterThanOrEqual(columnName, from)
+	}
+	return newRangeStmt(OpInRange, "?0 >= ?1 AND ?0 < ?2", columnName, from, to)
+}
+
+// WithinLast matches a time column within the last d, up to now.
+func WithinLast(columnName string, d time.Duration) *FieldCondition {
+	t := now()
+	return InRange(columnName, t.Add(-d), t)
+}
+
+// WithinLastDays matches a time column from the start of the day, in loc,
+// days-1 days ago, so WithinLastDays(col, 1, loc) is today.
+func WithinLastDays(columnName string, days int, loc *time.Location) *FieldCondition {
+	today := startOfDay(now(), loc)
+	return InRange(columnName, today.AddDate(0, 0, 1-days), today.AddDate(0, 0, 1))
+}
+
+// OnDay matches a time column on the calendar day of day in loc, e.g
+// every row of the 1st of January in Lagos.
+func OnDay(columnName string, day time.Time, loc *time.Location) *FieldCondition {
+	return BetweenDays(columnName, day, day, loc)
+}
+
+// BetweenDays matches a time column from the start of the calendar day of
+// "from" to the end of the calendar day of "to", in loc.
+func BetweenDays(columnName string, from, to time.Time, loc *time.Location) *FieldCondition {
+	return InRange(columnName, startOfDay(from, loc), startOfDay(to, loc).AddDate(0, 0, 1))
+}
+
+// startOfDay returns the midnight of t's calendar day in loc, UTC by default.
+func startOfDay(t time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		loc = time.UTC
+	}
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// utc dereferences pointers, so any nil pointer is an open bound, and
+// converts times to UTC, so they compare as expected with times stored as
+// text, e.g on SQLite.
+func utc(v any) any {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil
+	}
+
+	v = rv.Interface()
+	if t, ok := v.(time.Time); ok {
+		return t.UTC()
+	}
+	return v
+}
@@ -0,0 +1,78 @@
+package filter
+
+import (
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// regexpExpr matches a column against a regular expression, with the ~
+// operators on Postgres and the REGEXP function elsewhere. SQLite has no
+// REGEXP function of its own: dbstore.NewDBConnection registers one backed
+// by Go's regexp package, whose syntax differs from Postgres' in places, e.g
+// Go has no back-references.
+type regexpExpr struct {
+	column  bun.Ident
+	pattern string
+	not     bool
+	fold    bool
+}
+
+func (e *regexpExpr) AppendQuery(fmter schema.Formatter, b []byte) ([]byte, error) {
+	if fmter.Dialect().Name() == dialect.PG {
+		op := "~"
+		if e.not {
+			op = "!~"
+		}
+		if e.fold {
+			op += "*"
+		}
+		return fmter.AppendQuery(b, "? "+op+" ?", e.column, e.pattern), nil
+	}
+
+	op, pattern := "REGEXP", e.pattern
+	if e.not {
+		op = "NOT REGEXP"
+	}
+	if e.fold {
+		pattern = "(?i)" + pattern
+	}
+	return fmter.AppendQuery(b, "? "+op+" ?", e.column, pattern), nil
+}
+
+func newRegexpStmt(op Operator, columnName string, pattern string, e *regexpExpr) *FieldCondition {
+	if pattern == "" {
+		return nil
+	}
+
+	e.pattern = pattern
+	return &FieldCondition{
+		stmt:       "?",
+		columnName: columnName,
+		expr: func(column bun.Ident) schema.QueryAppender {
+			e := *e
+			e.column = column
+			return &e
+		},
+		op:    op,
+		value: pattern,
+	}
+}
+
+// Matches matches a column against a regular expression, matching case.
+func Matches(columnName string, pattern string) *FieldCondition {
+	return newRegexpStmt(OpMatches, columnName, pattern, &regexpExpr{})
+}
+
+func NotMatches(columnName string, pattern string) *FieldCondition {
+	return newRegexpStmt(OpNotMatches, columnName, pattern, &regexpExpr{not: true})
+}
+
+// MatchesFold is Matches, ignoring case.
+func MatchesFold(columnName string, pattern string) *FieldCondition {
+	return newRegexpStmt(OpMatchesFold, columnName, pattern, &regexpExpr{fold: true})
+}
+
+func NotMatchesFold(columnName string, pattern string) *FieldCondition {
+	return newRegexpStmt(OpNotMatchesFold, columnName, pattern, &regexpExpr{not: true, fold: true})
+}
@@ -0,0 +1,117 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// isRelationPath reports whether a column is on a relation path, e.g
+// "Author.name" or "Author.Publisher.name": relations are named after their
+// Go field, so unlike table aliases they start with an upper case letter.
+func isRelationPath(column string) bool {
+	name, _, qualified := strings.Cut(column, ".")
+	return qualified && name != "" && unicode.IsUpper([]rune(name)[0])
+}
+
+// resolveRelationPath resolves a column on a relation path of the table to
+// the path and to the column qualified by the alias bun joins the relation
+// with, e.g "Author.Publisher.name" to "Author.Publisher" and
+// "author__publisher.name".
+func resolveRelationPath(table *schema.Table, column string) (string, string, error) {
+	names := strings.Split(column, ".")
+	names, column = names[:len(names)-1], names[len(names)-1]
+
+	aliases := make([]string, len(names))
+	for i, name := range names {
+		rel, ok := table.Relations[name]
+		if !ok {
+			return "", "", fmt.Errorf("%s has no relation %q", table.TypeName, name)
+		}
+		if rel.Type != schema.HasOneRelation && rel.Type != schema.BelongsToRelation {
+			return "", "", fmt.Errorf("%s.%s is not a has-one or belongs-to relation, filter it with Exists", table.TypeName, name)
+		}
+		aliases[i] = rel.Field.Name
+		table = rel.JoinTable
+	}
+
+	if !table.HasField(column) {
+		return "", "", fmt.Errorf("%s has no column %q", table.TypeName, column)
+	}
+	return strings.Join(names, "."), strings.Join(aliases, "__") + "." + column, nil
+}
+
+// joinRelations joins the relations the condition's columns are on, e.g
+// Author for "Author.name", and returns the condition with these columns
+// qualified by the relation alias, e.g "author.name". Paths in joined are
+// not joined again, and are added to it when not nil. Queries without a
+// model are left as they are.
+func joinRelations[T allBunQueryType](bunQ T, table *schema.Table, cond Condition, joined map[string]bool) (Condition, *FieldError) {
+	if table == nil {
+		return cond, nil
+	}
+
+	var columns map[string]string
+
+	for _, column := range conditionColumns(cond) {
+		if !isRelationPath(column) {
+			continue
+		}
+
+		path, qualified, err := resolveRelationPath(table, column)
+		if err != nil {
+			return nil, &FieldError{Param: column, Message: err.Error()}
+		}
+
+		q, ok := any(bunQ).(*bun.SelectQuery)
+		if !ok {
+			return nil, &FieldError{Param: column, Message: "relation columns only work with Select Query"}
+		}
+		if !joined[path] {
+			q.Relation(path)
+			if joined != nil {
+				joined[path] = true
+			}
+		}
+
+		if columns == nil {
+			columns = make(map[string]string)
+		}
+		columns[column] = qualified
+	}
+
+	if columns == nil {
+		return cond, nil
+	}
+	return withColumns(cond, columns), nil
+}
+
+// withColumns copies the condition with its columns renamed.
+func withColumns(cond Condition, columns map[string]string) Condition {
+	switch c := cond.(type) {
+	case *FieldCondition:
+		if column, ok := columns[c.columnName]; ok {
+			renamed := *c
+			renamed.columnName = column
+			return &renamed
+		}
+	case *Subquery:
+		if column, ok := columns[c.columnName]; ok {
+			renamed := *c
+			renamed.columnName = column
+			return &renamed
+		}
+	case *Group:
+		g := &Group{or: c.or, conditions: make([]Condition, len(c.conditions))}
+		for i := range c.conditions {
+			g.conditions[i] = withColumns(c.conditions[i], columns)
+		}
+		return g
+	case *Negation:
+		return &Negation{condition: withColumns(c.condition, columns)}
+	}
+	return cond
+}
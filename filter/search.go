@@ -0,0 +1,104 @@
+package filter
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+
+	dbstore "github.com/otyang/go-dbstore"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/schema"
+)
+
+// FullTextSearch matches rows with the full-text search index created on
+// columns of the model's table by the seeder's CreateSearchIndex.
+type FullTextSearch struct {
+	model   any
+	columns []string
+	words   []string
+}
+
+// Search matches the rows of the model whose indexed columns contain every
+// word of a user query, the last one as a prefix, so "go dat" matches
+// "Go databases". The query is reduced to its words, so characters with
+// a meaning for the engine, e.g quotes or "&", cannot break the search.
+// A query without words is skipped. The columns must be the indexed ones,
+// in the same order.
+func Search(model any, query string, columns ...string) *FullTextSearch {
+	return &FullTextSearch{model: model, columns: columns, words: searchWords(query)}
+}
+
+// searchWords splits a query into words of letters and numbers.
+func searchWords(query string) []string {
+	return strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func (s *FullTextSearch) isEmpty() bool {
+	return s == nil || len(s.words) == 0 || len(s.columns) == 0
+}
+
+func (s *FullTextSearch) applyTo(q whereQuery, or bool) {
+	if or {
+		q.whereOr("?", s)
+		return
+	}
+	q.where("?", s)
+}
+
+func (s *FullTextSearch) AppendQuery(fmter schema.Formatter, b []byte) ([]byte, error) {
+	table := fmter.Dialect().Tables().Get(reflect.TypeOf(s.model))
+
+	if fmter.Dialect().Name() == dialect.PG {
+		return fmter.AppendQuery(b, "? @@ to_tsquery(?, ?)", s.vector(table), dbstore.SearchConfig, s.tsquery()), nil
+	}
+
+	fts := bun.Ident(dbstore.SearchTable(table.Name))
+	return fmter.AppendQuery(b, "?.rowid IN (SELECT rowid FROM ? WHERE ? MATCH ?)",
+		table.SQLAlias, fts, fts, s.match()), nil
+}
+
+// OrderByRank orders the query by relevance to the search, most relevant
+// first, with ts_rank on Postgres and bm25 on SQLite.
+func (s *FullTextSearch) OrderByRank(q *bun.SelectQuery) {
+	if s.isEmpty() {
+		return
+	}
+
+	table := q.Dialect().Tables().Get(reflect.TypeOf(s.model))
+
+	if q.Dialect().Name() == dialect.PG {
+		q.OrderExpr("ts_rank(?, to_tsquery(?, ?)) DESC", s.vector(table), dbstore.SearchConfig, s.tsquery())
+		return
+	}
+
+	// bm25 is lower for better matches
+	fts := bun.Ident(dbstore.SearchTable(table.Name))
+	q.OrderExpr("(SELECT bm25(?) FROM ? WHERE ? MATCH ? AND rowid = ?.rowid) ASC",
+		fts, fts, fts, s.match(), table.SQLAlias)
+}
+
+func (s *FullTextSearch) vector(table *schema.Table) schema.QueryAppender {
+	columns := make([]string, len(s.columns))
+	for i, column := range s.columns {
+		columns[i] = table.Alias + "." + column
+	}
+	return dbstore.SearchVector(columns...)
+}
+
+// tsquery is the Postgres query of the words, e.g "go & dat:*".
+func (s *FullTextSearch) tsquery() string {
+	return strings.Join(s.words, " & ") + ":*"
+}
+
+// match is the FTS5 query of the words, quoted so that words such as NOT
+// are not operators, e.g `"go" "dat"*`.
+func (s *FullTextSearch) match() string {
+	terms := make([]string, len(s.words))
+	for i, word := range s.words {
+		terms[i] = `"` + word + `"`
+	}
+	return strings.Join(terms, " ") + "*"
+}
@@ -0,0 +1,132 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// Nulls places NULL values in a sort.
+type Nulls int
+
+const (
+	NullsDefault Nulls = iota // where the database puts them
+	NullsFirst
+	NullsLast
+)
+
+// SortField sorts on one column.
+type SortField struct {
+	Column string
+	Desc   bool
+	Nulls  Nulls
+}
+
+// SortSpec sorts on several columns, in order.
+type SortSpec []SortField
+
+// ParseSort parses a comma separated list of columns, e.g "-created_at,title".
+// A "-" prefix sorts a column in descending order, an optional "+" in
+// ascending order, and a ":nullsfirst" or ":nullslast" suffix places its NULL
+// values, e.g "-published_at:nullslast". Only one sign is allowed per column.
+// When allowed is not nil, only its columns can be sorted on.
+func ParseSort(spec string, allowed []string) (SortSpec, error) {
+	var s SortSpec
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		column, nulls, _ := strings.Cut(item, ":")
+		f := SortField{Column: column, Desc: strings.HasPrefix(column, "-")}
+		if f.Desc || strings.HasPrefix(column, "+") {
+			f.Column = column[1:]
+		}
+		if strings.HasPrefix(f.Column, "-") || strings.HasPrefix(f.Column, "+") {
+			return nil, &FieldError{Param: "sort", Message: fmt.Sprintf("%q has more than one sign", item)}
+		}
+
+		switch strings.ToLower(nulls) {
+		case "":
+		case "nullsfirst":
+			f.Nulls = NullsFirst
+		case "nullslast":
+			f.Nulls = NullsLast
+		default:
+			return nil, &FieldError{Param: "sort", Message: fmt.Sprintf("unknown option %q on %q", nulls, f.Column)}
+		}
+
+		if f.Column == "" {
+			return nil, &FieldError{Param: "sort", Message: fmt.Sprintf("%q has no column", item)}
+		}
+		if allowed != nil && !containsString(allowed, f.Column) {
+			return nil, &FieldError{Param: "sort", Message: fmt.Sprintf("cannot sort on %q", f.Column)}
+		}
+
+		s = append(s, f)
+	}
+
+	return s, nil
+}
+
+// String formats the spec the way ParseSort reads it.
+func (s SortSpec) String() string {
+	items := make([]string, len(s))
+	for i, f := range s {
+		if f.Desc {
+			items[i] = "-"
+		}
+		items[i] += f.Column
+
+		switch f.Nulls {
+		case NullsFirst:
+			items[i] += ":nullsfirst"
+		case NullsLast:
+			items[i] += ":nullslast"
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// Apply orders the query by the spec, quoting the columns. Postgres places
+// NULL values natively with NULLS FIRST/LAST, other dialects, e.g SQLite
+// before 3.30, get it emulated by sorting on "column IS NULL" first.
+func (s SortSpec) Apply(q *bun.SelectQuery) {
+	native := q.Dialect().Name() == dialect.PG
+
+	for _, f := range s {
+		var (
+			column    = bun.Ident(f.Column)
+			direction = "ASC"
+		)
+		if f.Desc {
+			direction = "DESC"
+		}
+
+		switch {
+		case f.Nulls == NullsDefault:
+			q.OrderExpr("? "+direction, column)
+		case native && f.Nulls == NullsFirst:
+			q.OrderExpr("? "+direction+" NULLS FIRST", column)
+		case native:
+			q.OrderExpr("? "+direction+" NULLS LAST", column)
+		case f.Nulls == NullsFirst:
+			q.OrderExpr("? IS NULL DESC, ? "+direction, column, column)
+		default:
+			q.OrderExpr("? IS NULL ASC, ? "+direction, column, column)
+		}
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for i := range list {
+		if list[i] == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package filter
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	dbstore "github.com/otyang/go-dbstore"
+)
+
+// FromStruct builds the conditions declared by the `filter` tags of a struct,
+// joined with "AND". A tag names the column and optionally the operator,
+// which defaults to eq:
+//
+//	type BookFilter struct {
+//		Title   *string `filter:"title,op=contains"`
+//		MinYear *int    `filter:"year,op=gte"`
+//	}
+//
+// Nil and zero fields are skipped, except pointers to a zero value, which
+// are set on purpose. Untagged fields, and fields tagged "-", are ignored.
+func FromStruct(v any) (*Group, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("filter struct: expected a struct, got %T", v)
+	}
+
+	conds, err := structConditions(rv)
+	if err != nil {
+		return nil, fmt.Errorf("filter struct: %w", err)
+	}
+	return And(conds...), nil
+}
+
+// StructCriteria is FromStruct as a dbstore.SelectCriteria.
+func StructCriteria(v any) (dbstore.SelectCriteria, error) {
+	cond, err := FromStruct(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return SelectWhere(cond), nil
+}
+
+func structConditions(rv reflect.Value) ([]Condition, error) {
+	var conds []Condition
+
+	for i := 0; i < rv.NumField(); i++ {
+		sf := rv.Type().Field(i)
+		tag, tagged := sf.Tag.Lookup("filter")
+
+		if !tagged && sf.Anonymous {
+			embedded := reflect.Indirect(rv.Field(i))
+			if embedded.Kind() == reflect.Struct {
+				nested, err := structConditions(embedded)
+				if err != nil {
+					return nil, err
+				}
+				conds = append(conds, nested...)
+			}
+			continue
+		}
+		if !tagged || tag == "-" || !sf.IsExported() {
+			continue
+		}
+
+		column, op, err := parseFilterTag(tag)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+
+		value, ok := structFieldValue(rv.Field(i))
+		if !ok {
+			continue
+		}
+
+		cond, err := NewCondition(op, column, value)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", sf.Name, err)
+		}
+		if cond != nil {
+			conds = append(conds, cond)
+		}
+	}
+
+	return conds, nil
+}
+
+func parseFilterTag(tag string) (string, Operator, error) {
+	column, options, _ := strings.Cut(tag, ",")
+	column = strings.TrimSpace(column)
+	if column == "" {
+		return "", "", fmt.Errorf("tag %q has no column", tag)
+	}
+
+	op := OpEqual
+	for _, option := range strings.Split(options, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+		switch key {
+		case "":
+		case "op":
+			op = Operator(value)
+		default:
+			return "", "", fmt.Errorf("tag %q has unknown option %q", tag, key)
+		}
+	}
+
+	if !op.IsValid() {
+		return "", "", fmt.Errorf("tag %q has unknown operator %q", tag, op)
+	}
+	return column, op, nil
+}
+
+// structFieldValue returns the value of a field, reporting false when it
+// is nil, or zero without being behind a pointer.
+func structFieldValue(fv reflect.Value) (any, bool) {
+	if fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			return nil, false
+		}
+		fv = fv.Elem()
+	} else if fv.IsZero() || (fv.Kind() == reflect.Slice && fv.Len() == 0) {
+		return nil, false
+	}
+
+	// named string types, e.g type Status string, feed the string operators
+	if fv.Kind() == reflect.String {
+		return fv.String(), true
+	}
+	return fv.Interface(), true
+}
@@ -0,0 +1,147 @@
+package filter
+
+import (
+	"reflect"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// Subquery filters rows on the rows of another query, with EXISTS or IN.
+// The other query is either a *bun.SelectQuery or a From, and can refer to
+// the filtered query, e.g with Correlate:
+//
+//	// authors with at least one book published after 2020
+//	q := db.NewSelect().Model(&authors)
+//	filter.Where(q, filter.Exists(filter.From((*Book)(nil),
+//		filter.Correlate("book.author_id", "author.id"),
+//		filter.Gt("book.published_at", date),
+//	)))
+type Subquery struct {
+	stmt       string
+	columnName string
+	query      schema.QueryAppender
+}
+
+// Exists matches when the subquery returns a row.
+func Exists(sub schema.QueryAppender) *Subquery {
+	return &Subquery{stmt: "EXISTS (?)", query: sub}
+}
+
+// NotExists matches when the subquery returns no row.
+func NotExists(sub schema.QueryAppender) *Subquery {
+	return &Subquery{stmt: "NOT EXISTS (?)", query: sub}
+}
+
+// InSubquery matches a column with a value returned by the subquery,
+// which must select one column.
+func InSubquery(columnName string, sub schema.QueryAppender) *Subquery {
+	return &Subquery{stmt: "? IN (?)", columnName: columnName, query: sub}
+}
+
+// NotInSubquery matches a column with none of the values returned by the
+// subquery. As with NOT IN, a NULL returned by the subquery matches nothing.
+func NotInSubquery(columnName string, sub schema.QueryAppender) *Subquery {
+	return &Subquery{stmt: "? NOT IN (?)", columnName: columnName, query: sub}
+}
+
+func (s *Subquery) isEmpty() bool {
+	return s == nil || isNilAppender(s.query)
+}
+
+func (s *Subquery) applyTo(q whereQuery, or bool) {
+	if or {
+		q.whereOr("?", s)
+		return
+	}
+	q.where("?", s)
+}
+
+func (s *Subquery) AppendQuery(fmter schema.Formatter, b []byte) ([]byte, error) {
+	if s.columnName == "" {
+		return fmter.AppendQuery(b, s.stmt, s.query), nil
+	}
+	return fmter.AppendQuery(b, s.stmt, bun.Ident(s.columnName), s.query), nil
+}
+
+func isNilAppender(q schema.QueryAppender) bool {
+	if q == nil {
+		return true
+	}
+	v := reflect.ValueOf(q)
+	return v.Kind() == reflect.Pointer && v.IsNil()
+}
+
+// ModelQuery selects from the table of a model, for subqueries that are
+// built without a database. See From.
+type ModelQuery struct {
+	model      any
+	columns    []string
+	conditions *Group
+}
+
+// From selects the rows of the model's table matching the conditions,
+// which refer to the table by its alias, e.g "book.author_id". It selects
+// nothing but 1, which is all Exists needs: use Column for InSubquery.
+func From(model any, conds ...Condition) *ModelQuery {
+	return &ModelQuery{model: model, conditions: And(conds...)}
+}
+
+// Column sets the selected columns.
+func (m *ModelQuery) Column(columns ...string) *ModelQuery {
+	m.columns = columns
+	return m
+}
+
+func (m *ModelQuery) AppendQuery(fmter schema.Formatter, b []byte) ([]byte, error) {
+	table := fmter.Dialect().Tables().Get(reflect.TypeOf(m.model))
+
+	b = append(b, "SELECT "...)
+	if len(m.columns) == 0 {
+		b = append(b, '1')
+	}
+	for i, column := range m.columns {
+		if i > 0 {
+			b = append(b, ", "...)
+		}
+		b = fmter.AppendQuery(b, "?", bun.Ident(column))
+	}
+
+	b = fmter.AppendQuery(b, " FROM ? AS ?", table.SQLNameForSelects, table.SQLAlias)
+	if m.conditions.isEmpty() {
+		return b, nil
+	}
+
+	b = append(b, " WHERE "...)
+	return m.conditions.AppendQuery(fmter, b)
+}
+
+// columnEquality compares two columns, e.g of a subquery and of the query
+// it filters.
+type columnEquality struct {
+	columnName  string
+	otherColumn string
+}
+
+// Correlate matches a column with a column of an outer query, to correlate
+// a subquery with the rows it filters, e.g Correlate("book.author_id",
+// "author.id") in a subquery on books filtering authors.
+func Correlate(columnName, outerColumn string) Condition {
+	return &columnEquality{columnName: columnName, otherColumn: outerColumn}
+}
+
+func (c *columnEquality) isEmpty() bool {
+	return c == nil
+}
+
+func (c *columnEquality) applyTo(q whereQuery, or bool) {
+	if or {
+		q.whereOr("?", c)
+		return
+	}
+	q.where("?", c)
+}
+
+func (c *columnEquality) AppendQuery(fmter schema.Formatter, b []byte) ([]byte, error) {
+	return fmter.AppendQuery(b, "? = ?", bun.Ident(c.columnName), bun.Ident(c.otherColumn)), nil
+}
@@ -0,0 +1,50 @@
+package filter
+
+// A Visitor's Visit method is invoked by Walk for each condition of a tree.
+// If the visitor w it returns is not nil, Walk visits each of the children
+// of the condition with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(cond Condition) (w Visitor)
+}
+
+// Walk traverses a condition tree in depth-first order, as ast.Walk does.
+// The children of a Group are its conditions and the child of a Negation
+// is the condition it negates. Skipped conditions are not visited.
+//
+// Tooling can walk a tree to collect the columns it refers to, or to
+// translate it, switching on *FieldCondition, *Group and *Negation.
+func Walk(v Visitor, cond Condition) {
+	if isEmpty(cond) {
+		return
+	}
+	if v = v.Visit(cond); v == nil {
+		return
+	}
+
+	switch c := cond.(type) {
+	case *Group:
+		for _, child := range c.conditions {
+			Walk(v, child)
+		}
+	case *Negation:
+		Walk(v, c.condition)
+	}
+
+	v.Visit(nil)
+}
+
+type inspector func(Condition) bool
+
+func (f inspector) Visit(cond Condition) Visitor {
+	if f(cond) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a condition tree in depth-first order, as ast.Inspect
+// does: it calls f(cond) and, if f returns true, inspects the children of
+// cond, followed by a call of f(nil).
+func Inspect(cond Condition, f func(Condition) bool) {
+	Walk(inspector(f), cond)
+}
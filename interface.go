@@ -26,6 +26,11 @@ type IRepository interface {
 	// FindOneByPK retrieves a single record by its primary key.
 	FindOneByPK(ctx context.Context, modelPtr any) error
 
+	// FindManyByPKs retrieves the records with the given primary keys, querying
+	// them in chunks. Composite keys are given as []any. It optionally keeps the
+	// order of keys, and returns the keys that matched no record.
+	FindManyByPKs(ctx context.Context, modelsPtr any, keys []any, keepOrder bool) ([]any, error)
+
 	// FindOneWhere retrieves a single record matching the specified criteria.
 	FindOneWhere(ctx context.Context, modelPtr any, sc ...SelectCriteria) error
 
@@ -33,6 +38,26 @@ type IRepository interface {
 	// It supports pagination using the provided PaginationOption.
 	FindManyWhere(ctx context.Context, modelPtr any, opt PaginationOption, sc ...SelectCriteria) error
 
+	// FindOneProjected retrieves a single record matching the specified criteria,
+	// scanning a subset of its columns into destPtr. The columns are derived from
+	// the struct behind destPtr unless explicitly listed.
+	FindOneProjected(ctx context.Context, modelPtr any, destPtr any, columns []string, sc ...SelectCriteria) error
+
+	// FindManyProjected retrieves multiple records matching the specified criteria,
+	// scanning a subset of their columns into destPtr, a pointer to a slice.
+	// It supports pagination using the provided PaginationOption.
+	FindManyProjected(ctx context.Context, modelPtr any, destPtr any, columns []string, opt PaginationOption, sc ...SelectCriteria) error
+
+	// LoadRelations loads the named bun relations onto already fetched models,
+	// with one query per relation rather than one per model.
+	LoadRelations(ctx context.Context, modelsPtr any, relations ...string) error
+
+	// FindOrCreate retrieves a single record matching the specified criteria,
+	// inserting modelPtr as is when none is found. A duplicate raised by a
+	// concurrent insert is resolved by reading the record again.
+	// It reports whether the record was created.
+	FindOrCreate(ctx context.Context, modelPtr any, sc ...SelectCriteria) (bool, error)
+
 	// UpdateOneByPK updates a single record by its primary key.
 	UpdateOneByPK(ctx context.Context, modelsPtr any) error
 
@@ -42,12 +67,21 @@ type IRepository interface {
 	// UpdateOneWhere updates a single record matching the specified criteria.
 	UpdateOneWhere(ctx context.Context, modelPtr any, uc ...UpdateCriteria) error
 
+	// CompareAndSwap sets values on the record identified by the primary key of
+	// modelPtr, but only while its columns still hold the expected values.
+	// It reports whether the record was changed and returns sql.ErrNoRows
+	// when the record does not exist.
+	CompareAndSwap(ctx context.Context, modelPtr any, expected map[string]any, values map[string]any) (bool, error)
+
 	// Upsert inserts a record if it doesn't exist, or updates it if it does.
 	Upsert(ctx context.Context, modelsPtr any) error
 
 	// DeleteByPK deletes a single record by its primary key.
 	DeleteByPK(ctx context.Context, modelsPtr any) error
 
+	// DeleteManyByPK deletes multiple records by their primary keys.
+	DeleteManyByPK(ctx context.Context, modelsPtr any) error
+
 	// DeleteWhere deletes multiple records matching the specified criteria.
 	DeleteWhere(ctx context.Context, modelPtr any, dc ...DeleteCriteria) error
 
@@ -2,6 +2,8 @@ package obun
 
 import (
 	"context"
+	"fmt"
+	"reflect"
 
 	dbstore "github.com/otyang/go-dbstore"
 	"github.com/uptrace/bun"
@@ -98,38 +100,34 @@ func (r *Repository) FindOneByPK(ctx context.Context, modelPtr any) error {
 	return r.db.NewSelect().Model(modelPtr).WherePK().Limit(1).Scan(ctx)
 }
 
+// FindOneWhere retrieves a single record matching the criteria, with the
+// default scopes of its model applied, see dbstore.RegisterDefaultScope.
 func (r *Repository) FindOneWhere(ctx context.Context, modelPtr any, sc ...SelectCriteria) error {
-	q := r.db.NewSelect().Model(modelPtr)
-
-	for i := range sc {
-		if sc[i] == nil {
-			continue
-		}
-		sc[i](q)
-	}
-
+	q := dbstore.ApplySelectCriteria(r.db.NewSelect().Model(modelPtr), sc...)
 	return q.Limit(1).Scan(ctx)
 }
 
+// FindManyWhere retrieves the records matching the criteria, with the
+// default scopes of their model applied, see dbstore.RegisterDefaultScope.
 func (r *Repository) FindManyWhere(ctx context.Context, modelPtr any, opt dbstore.PaginationOption, sc ...SelectCriteria) error {
-	q := r.db.NewSelect().Model(modelPtr)
-	for i := range sc {
-		if sc[i] == nil {
-			continue
-		}
-		sc[i](q)
+	q := dbstore.ApplySelectCriteria(r.db.NewSelect().Model(modelPtr), sc...)
+
+	q, err := paginate(q, opt)
+	if err != nil {
+		return err
 	}
+	return q.Scan(ctx)
+}
 
+// paginate applies the cursor pagination option, if any, to q.
+func paginate(q *bun.SelectQuery, opt dbstore.PaginationOption) (*bun.SelectQuery, error) {
 	if opt == nil {
-		if err := q.Scan(ctx); err != nil {
-			return err
-		}
-		return nil
+		return q, nil
 	}
 
 	o := dbstore.PaginationParams{}
 	if err := opt(&o); err != nil {
-		return err
+		return nil, err
 	}
 
 	// decide if sort defined or predefined
@@ -138,12 +136,114 @@ func (r *Repository) FindManyWhere(ctx context.Context, modelPtr any, opt dbstor
 		if o.CursorValue != "" {
 			q = q.Where("? >= ?", bun.Ident(o.CursorColumn), o.CursorValue)
 		}
-		return q.Scan(ctx)
+		return q, nil
 	}
 
 	q = q.OrderExpr(o.CursorColumn + " DESC").Limit(o.Limit)
 	if o.CursorValue != "" {
 		q = q.Where("? <= ?", bun.Ident(o.CursorColumn), o.CursorValue)
 	}
-	return q.Scan(ctx)
+	return q, nil
+}
+
+// FindOneProjected retrieves a single record matching the criteria from the
+// table of modelPtr, scanning only the projected columns into destPtr.
+// When columns is empty they are derived from the bun tags of destPtr.
+// Default scopes are applied as with FindOneWhere.
+func (r *Repository) FindOneProjected(ctx context.Context, modelPtr any, destPtr any, columns []string, sc ...SelectCriteria) error {
+	q, err := r.projectedQuery(modelPtr, destPtr, columns, sc)
+	if err != nil {
+		return err
+	}
+	return q.Limit(1).Scan(ctx, destPtr)
+}
+
+// FindManyProjected retrieves the records matching the criteria from the table
+// of modelPtr, scanning only the projected columns into destPtr, a pointer to
+// a slice. When columns is empty they are derived from the bun tags of destPtr.
+// Default scopes are applied as with FindManyWhere.
+func (r *Repository) FindManyProjected(ctx context.Context, modelPtr any, destPtr any, columns []string, opt dbstore.PaginationOption, sc ...SelectCriteria) error {
+	q, err := r.projectedQuery(modelPtr, destPtr, columns, sc)
+	if err != nil {
+		return err
+	}
+
+	q, err = paginate(q, opt)
+	if err != nil {
+		return err
+	}
+	return q.Scan(ctx, destPtr)
+}
+
+func (r *Repository) projectedQuery(modelPtr any, destPtr any, columns []string, sc []SelectCriteria) (*bun.SelectQuery, error) {
+	columns, err := projectionColumns(r.db, modelPtr, destPtr, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	return dbstore.ApplySelectCriteria(r.db.NewSelect().Model(modelPtr).Column(columns...), sc...), nil
+}
+
+// projectionColumns returns columns, or the columns of the struct behind
+// destPtr when none are given, checking each belongs to the model's table.
+func projectionColumns(db bun.IDB, modelPtr any, destPtr any, columns []string) ([]string, error) {
+	model := db.Dialect().Tables().Get(structType(reflect.TypeOf(modelPtr)))
+
+	if len(columns) == 0 {
+		typ := structType(reflect.TypeOf(destPtr))
+		if typ.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("projection: cannot derive columns from %T", destPtr)
+		}
+		for _, field := range db.Dialect().Tables().Get(typ).Fields {
+			columns = append(columns, field.Name)
+		}
+	}
+
+	for _, column := range columns {
+		if !model.HasField(column) {
+			return nil, fmt.Errorf("projection: %q is not a column of %s", column, model.TypeName)
+		}
+	}
+	return columns, nil
+}
+
+// structType unwraps pointers and slices down to the element type.
+func structType(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+// LoadRelations loads the named relations onto modelsPtr, a pointer to
+// a model or to a slice of models that were already fetched.
+func (r *Repository) LoadRelations(ctx context.Context, modelsPtr any, relations ...string) error {
+	return dbstore.LoadRelations(ctx, r.db, modelsPtr, relations...)
+}
+
+// FindOrCreate looks up a record with the given criteria and inserts modelPtr
+// when nothing is found, see dbstore.FindOrCreate. It runs in the ambient
+// transaction when the repository was built with one.
+func (r *Repository) FindOrCreate(ctx context.Context, modelPtr any, sc ...SelectCriteria) (bool, error) {
+	return dbstore.FindOrCreate(ctx, r.db, modelPtr, sc...)
+}
+
+// CompareAndSwap updates the record identified by the primary key of modelPtr
+// only when its current column values match expected, see
+// dbstore.CompareAndSwap.
+func (r *Repository) CompareAndSwap(ctx context.Context, modelPtr any, expected map[string]any, values map[string]any) (bool, error) {
+	return dbstore.CompareAndSwap(ctx, r.db, modelPtr, expected, values)
+}
+
+// FindManyByPKs retrieves the records whose primary keys are listed in keys
+// into modelsPtr, see dbstore.FindManyByPKs. The keys that matched no record
+// are returned.
+func (r *Repository) FindManyByPKs(ctx context.Context, modelsPtr any, keys []any, keepOrder bool) ([]any, error) {
+	return dbstore.FindManyByPKs(ctx, r.db, modelsPtr, keys, keepOrder)
+}
+
+// DeleteManyByPK deletes the slice of models pointed to by modelsPtr by their
+// primary keys, in chunks, within a single transaction.
+func (r *Repository) DeleteManyByPK(ctx context.Context, modelsPtr any) error {
+	return dbstore.DeleteManyByPK(ctx, r.db, modelsPtr)
 }
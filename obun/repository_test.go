@@ -4,7 +4,9 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"testing"
+	"time"
 
 	dbstore "github.com/otyang/go-dbstore"
 	"github.com/otyang/go-dbstore/filter"
@@ -309,3 +311,514 @@ func TestRepository_Transaction(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestRepository_FindOrCreate(t *testing.T) {
+	var (
+		ctx, _, repo, tearDown = setUpMigrateAndTearDown(t, (*Book)(nil))
+		err                    = repo.CreateBulk(ctx, &seed, false)
+	)
+
+	defer tearDown()
+	assert.NoError(t, err)
+
+	t.Run("existing record is found", func(t *testing.T) {
+		got := Book{Id: "2", Title: "should not be inserted"}
+		created, err := repo.FindOrCreate(ctx, &got, func(q *bun.SelectQuery) *bun.SelectQuery {
+			filter.Where(q, filter.Equal("id", "2"))
+			return q
+		})
+		assert.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, seed[1], got)
+	})
+
+	t.Run("missing record is created", func(t *testing.T) {
+		want := Book{Id: "5", Title: "Title 5"}
+		got := want
+		created, err := repo.FindOrCreate(ctx, &got, func(q *bun.SelectQuery) *bun.SelectQuery {
+			filter.Where(q, filter.Equal("id", "5"))
+			return q
+		})
+		assert.NoError(t, err)
+		assert.True(t, created)
+
+		err = repo.FindOneByPK(ctx, &got)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("runs in the ambient transaction", func(t *testing.T) {
+		err := repo.Transaction(ctx, func(ctx context.Context, tx bun.Tx) error {
+			got := Book{Id: "6", Title: "Title 6"}
+			created, err := repo.NewWithTx(tx).FindOrCreate(ctx, &got, func(q *bun.SelectQuery) *bun.SelectQuery {
+				filter.Where(q, filter.Equal("id", "6"))
+				return q
+			})
+			assert.NoError(t, err)
+			assert.True(t, created)
+
+			return errors.New("deliberate-rollback")
+		})
+		assert.Error(t, err)
+
+		err = repo.FindOneByPK(ctx, &Book{Id: "6"})
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+}
+
+func TestRepository_CompareAndSwap(t *testing.T) {
+	var (
+		ctx, _, repo, tearDown = setUpMigrateAndTearDown(t, (*Book)(nil))
+		err                    = repo.CreateBulk(ctx, &seed, false)
+	)
+
+	defer tearDown()
+	assert.NoError(t, err)
+
+	var (
+		expected = map[string]any{"title": "Title 1"}
+		values   = map[string]any{"title": "Swapped Title 1"}
+	)
+
+	swapped, err := repo.CompareAndSwap(ctx, &Book{Id: "1"}, expected, values)
+	assert.NoError(t, err)
+	assert.True(t, swapped)
+
+	got := Book{Id: "1"}
+	err = repo.FindOneByPK(ctx, &got)
+	assert.NoError(t, err)
+	assert.Equal(t, "Swapped Title 1", got.Title)
+
+	// the expected value no longer matches
+	swapped, err = repo.CompareAndSwap(ctx, &Book{Id: "1"}, expected, values)
+	assert.NoError(t, err)
+	assert.False(t, swapped)
+
+	swapped, err = repo.CompareAndSwap(ctx, &Book{Id: "404"}, expected, values)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.False(t, swapped)
+}
+
+func TestRepository_FindManyByPKs(t *testing.T) {
+	type Edition struct {
+		BookId string `bun:",pk"`
+		Number int    `bun:",pk"`
+		Title  string `bun:",notnull"`
+	}
+	type Event struct {
+		Id int64     `bun:",pk"`
+		At time.Time `bun:",pk"`
+	}
+
+	var (
+		ctx, _, repo, tearDown = setUpMigrateAndTearDown(t, (*Book)(nil), (*Edition)(nil), (*Event)(nil))
+		err                    = repo.CreateBulk(ctx, &seed, false)
+	)
+
+	defer tearDown()
+	assert.NoError(t, err)
+
+	t.Run("in input order with missing keys", func(t *testing.T) {
+		var got []Book
+		missing, err := repo.FindManyByPKs(ctx, &got, []any{"3", "404", "1"}, true)
+		assert.NoError(t, err)
+		assert.Equal(t, []Book{seed[2], seed[0]}, got)
+		assert.Equal(t, []any{"404"}, missing)
+	})
+
+	t.Run("spanning several chunks", func(t *testing.T) {
+		// more keys than dbstore sends in a single IN list
+		const n = 1200
+		keys := make([]any, 0, n)
+		for i := 0; i < n; i++ {
+			keys = append(keys, fmt.Sprintf("%d", n-i))
+		}
+
+		var got []*Book
+		missing, err := repo.FindManyByPKs(ctx, &got, keys, true)
+		assert.NoError(t, err)
+		assert.Equal(t, len(seed), len(got))
+		assert.Equal(t, "4", got[0].Id)
+		assert.Equal(t, n-len(seed), len(missing))
+	})
+
+	t.Run("composite primary keys", func(t *testing.T) {
+		editions := []Edition{
+			{BookId: "1", Number: 1, Title: "First"},
+			{BookId: "1", Number: 2, Title: "Second"},
+		}
+		err := repo.CreateBulk(ctx, &editions, false)
+		assert.NoError(t, err)
+
+		var got []Edition
+		missing, err := repo.FindManyByPKs(ctx, &got, []any{[]any{"1", 2}, []any{"2", 1}}, true)
+		assert.NoError(t, err)
+		assert.Equal(t, []Edition{editions[1]}, got)
+		assert.Equal(t, []any{[]any{"2", 1}}, missing)
+
+		_, err = repo.FindManyByPKs(ctx, &got, []any{"1"}, false)
+		assert.Error(t, err)
+	})
+
+	t.Run("keys of other types than the primary key", func(t *testing.T) {
+		at := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+		events := []Event{{Id: 1, At: at}, {Id: 2, At: at}}
+		assert.NoError(t, repo.CreateBulk(ctx, &events, false))
+
+		var (
+			id  = int64(2)
+			loc = time.FixedZone("UTC+2", 2*60*60)
+			got []Event
+		)
+		missing, err := repo.FindManyByPKs(ctx, &got, []any{[]any{&id, at.In(loc)}, []any{1, at.In(time.Local)}, []any{3, at}}, true)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(got))
+		assert.Equal(t, int64(2), got[0].Id)
+		assert.Equal(t, []any{[]any{3, at}}, missing)
+	})
+}
+
+func TestRepository_DeleteManyByPK(t *testing.T) {
+	var (
+		ctx, _, repo, tearDown = setUpMigrateAndTearDown(t, (*Book)(nil))
+		err                    = repo.CreateBulk(ctx, &seed, false)
+	)
+
+	defer tearDown()
+	assert.NoError(t, err)
+
+	err = repo.DeleteManyByPK(ctx, &[]Book{seed[0], seed[2]})
+	assert.NoError(t, err)
+
+	var got []Book
+	err = repo.FindManyWhere(ctx, &got, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []Book{seed[1], seed[3]}, got)
+
+	err = repo.DeleteManyByPK(ctx, &seed[0])
+	assert.Error(t, err)
+}
+
+func TestRepository_Projected(t *testing.T) {
+	type BookTitle struct {
+		Title string
+	}
+
+	var (
+		ctx, _, repo, tearDown = setUpMigrateAndTearDown(t, (*Book)(nil))
+		err                    = repo.CreateBulk(ctx, &seed, false)
+	)
+
+	defer tearDown()
+	assert.NoError(t, err)
+
+	t.Run("FindOneProjected with columns from struct tags", func(t *testing.T) {
+		var got BookTitle
+		err := repo.FindOneProjected(ctx, (*Book)(nil), &got, nil, func(q *bun.SelectQuery) *bun.SelectQuery {
+			filter.Where(q, filter.Equal("id", "2"))
+			return q
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, BookTitle{Title: seed[1].Title}, got)
+	})
+
+	t.Run("FindManyProjected with explicit columns", func(t *testing.T) {
+		var got []Book
+		err := repo.FindManyProjected(ctx, (*Book)(nil), &got, []string{"id"}, dbstore.WithCursor(2, true, "id", ""))
+		assert.NoError(t, err)
+		assert.Equal(t, []Book{{Id: "1"}, {Id: "2"}}, got)
+	})
+
+	t.Run("unknown columns are rejected", func(t *testing.T) {
+		var got []BookTitle
+		err := repo.FindManyProjected(ctx, (*Book)(nil), &got, []string{"isbn"}, nil)
+		assert.Error(t, err)
+	})
+}
+
+type (
+	Publisher struct {
+		Id   string `bun:",pk"`
+		Name string `bun:",notnull"`
+	}
+
+	Writer struct {
+		Id          string `bun:",pk"`
+		Name        string `bun:",notnull"`
+		PublisherId string
+		Publisher   *Publisher `bun:"rel:belongs-to,join:publisher_id=id"`
+		Novels      []Novel    `bun:"rel:has-many,join:id=writer_id"`
+		Genres      []*Genre   `bun:"m2m:writer_genres,join:Writer=Genre"`
+	}
+
+	Novel struct {
+		Id       string `bun:",pk"`
+		WriterId string
+		Writer   *Writer `bun:"rel:belongs-to,join:writer_id=id"`
+	}
+
+	Genre struct {
+		Id string `bun:",pk"`
+	}
+
+	WriterGenre struct {
+		bun.BaseModel `bun:"table:writer_genres"`
+
+		WriterId string  `bun:",pk"`
+		Writer   *Writer `bun:"rel:belongs-to,join:writer_id=id"`
+		GenreId  string  `bun:",pk"`
+		Genre    *Genre  `bun:"rel:belongs-to,join:genre_id=id"`
+	}
+
+	Review struct {
+		Id      string `bun:",pk"`
+		NovelId sql.NullString
+		Novel   *Novel `bun:"rel:belongs-to,join:novel_id=id"`
+	}
+
+	Shift struct {
+		Start time.Time `bun:",pk"`
+		Slots []Slot    `bun:"rel:has-many,join:start=shift_start"`
+	}
+
+	Slot struct {
+		Id         string `bun:",pk"`
+		ShiftStart time.Time
+	}
+)
+
+func TestRepository_LoadRelations(t *testing.T) {
+	ctx, db, repo, tearDown := setUpMigrateAndTearDown(t, (*Publisher)(nil), (*Genre)(nil), (*Review)(nil), (*Shift)(nil), (*Slot)(nil))
+	defer tearDown()
+
+	// the m2m intermediary model must be registered before the models using it
+	db.RegisterModel((*WriterGenre)(nil))
+	for _, model := range []any{(*Writer)(nil), (*Novel)(nil), (*WriterGenre)(nil)} {
+		_, err := db.NewCreateTable().Model(model).IfNotExists().Exec(ctx)
+		assert.NoError(t, err)
+		defer db.NewDropTable().Model(model).Exec(ctx)
+	}
+
+	var (
+		publishers   = []Publisher{{Id: "p1", Name: "Penguin"}}
+		writers      = []Writer{{Id: "w1", Name: "Ann", PublisherId: "p1"}, {Id: "w2", Name: "Bob"}}
+		novels       = []Novel{{Id: "n1", WriterId: "w1"}, {Id: "n2", WriterId: "w1"}, {Id: "n3", WriterId: "w2"}}
+		genres       = []Genre{{Id: "g1"}, {Id: "g2"}}
+		writerGenres = []WriterGenre{{WriterId: "w1", GenreId: "g1"}, {WriterId: "w1", GenreId: "g2"}}
+	)
+	for _, rows := range []any{&publishers, &writers, &novels, &genres, &writerGenres} {
+		assert.NoError(t, repo.CreateBulk(ctx, rows, false))
+	}
+
+	t.Run("onto already fetched models", func(t *testing.T) {
+		var got []Writer
+		err := repo.FindManyWhere(ctx, &got, dbstore.WithCursor(10, true, "id", ""))
+		assert.NoError(t, err)
+
+		err = repo.LoadRelations(ctx, &got, "Publisher", "Novels", "Genres")
+		assert.NoError(t, err)
+
+		assert.Equal(t, &publishers[0], got[0].Publisher)
+		assert.Equal(t, []Novel{novels[0], novels[1]}, got[0].Novels)
+		assert.Equal(t, []*Genre{&genres[0], &genres[1]}, got[0].Genres)
+
+		assert.Nil(t, got[1].Publisher)
+		assert.Equal(t, []Novel{novels[2]}, got[1].Novels)
+		assert.Empty(t, got[1].Genres)
+	})
+
+	t.Run("nested relations", func(t *testing.T) {
+		got := Novel{Id: "n1"}
+		err := repo.FindOneByPK(ctx, &got)
+		assert.NoError(t, err)
+
+		err = repo.LoadRelations(ctx, &got, "Writer.Publisher")
+		assert.NoError(t, err)
+		assert.Equal(t, "Ann", got.Writer.Name)
+		assert.Equal(t, "Penguin", got.Writer.Publisher.Name)
+	})
+
+	t.Run("keys of differing types", func(t *testing.T) {
+		reviews := []Review{{Id: "r1", NovelId: sql.NullString{String: "n1", Valid: true}}, {Id: "r2"}}
+		assert.NoError(t, repo.CreateBulk(ctx, &reviews, false))
+
+		err := repo.LoadRelations(ctx, &reviews, "Novel")
+		assert.NoError(t, err)
+		assert.Equal(t, &novels[0], reviews[0].Novel)
+		assert.Nil(t, reviews[1].Novel)
+	})
+
+	t.Run("time keys in different locations", func(t *testing.T) {
+		start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+		slots := []Slot{{Id: "s1", ShiftStart: start}, {Id: "s2", ShiftStart: start.Add(time.Hour)}}
+		assert.NoError(t, repo.CreateBulk(ctx, &[]Shift{{Start: start}}, false))
+		assert.NoError(t, repo.CreateBulk(ctx, &slots, false))
+
+		got := []Shift{{Start: start.In(time.FixedZone("WAT", 3600))}}
+		err := repo.LoadRelations(ctx, &got, "Slots")
+		assert.NoError(t, err)
+		if assert.Len(t, got[0].Slots, 1) {
+			assert.Equal(t, "s1", got[0].Slots[0].Id)
+		}
+	})
+
+	t.Run("unknown relation", func(t *testing.T) {
+		got := []Novel{novels[0]}
+		err := repo.LoadRelations(ctx, &got, "Editor")
+		assert.Error(t, err)
+	})
+
+	t.Run("WithRelations on Find methods", func(t *testing.T) {
+		got := Writer{}
+		err := repo.FindOneWhere(ctx, &got, dbstore.WithRelations("Publisher", "Novels"), func(q *bun.SelectQuery) *bun.SelectQuery {
+			filter.Where(q, filter.Equal("writer.id", "w1"))
+			return q
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "Penguin", got.Publisher.Name)
+		assert.Equal(t, 2, len(got.Novels))
+	})
+}
+
+type Post struct {
+	Id        string `bun:",pk"`
+	AuthorId  string
+	Published bool
+	Deleted   bool
+}
+
+func init() {
+	dbstore.RegisterDefaultScope((*Post)(nil), "not_deleted", func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("deleted = ?", false)
+	})
+	dbstore.RegisterDefaultScope((*Post)(nil), "published", func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("published = ?", true)
+	})
+	dbstore.RegisterScope((*Post)(nil), "by_author", func(args ...any) dbstore.SelectCriteria {
+		return func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("author_id = ?", args[0])
+		}
+	})
+}
+
+func TestRepository_Scopes(t *testing.T) {
+	var (
+		ctx, db, repo, tearDown = setUpMigrateAndTearDown(t, (*Post)(nil))
+		posts                   = []Post{
+			{Id: "1", AuthorId: "ann", Published: true},
+			{Id: "2", AuthorId: "ann", Published: false},
+			{Id: "3", AuthorId: "ann", Published: true, Deleted: true},
+			{Id: "4", AuthorId: "bob", Published: true},
+		}
+		err = repo.CreateBulk(ctx, &posts, false)
+	)
+
+	defer tearDown()
+	assert.NoError(t, err)
+
+	ids := func(sc ...dbstore.SelectCriteria) []string {
+		var got []Post
+		err := repo.FindManyWhere(ctx, &got, nil, append(sc, func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Order("id")
+		})...)
+		assert.NoError(t, err)
+
+		out := make([]string, len(got))
+		for i := range got {
+			out[i] = got[i].Id
+		}
+		return out
+	}
+
+	t.Run("default scopes", func(t *testing.T) {
+		assert.Equal(t, []string{"1", "4"}, ids())
+
+		var got Post
+		err := repo.FindOneWhere(ctx, &got, func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("id = ?", "3")
+		})
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+	})
+
+	t.Run("default scopes apply to every branch of an or", func(t *testing.T) {
+		either := func(q *bun.SelectQuery) *bun.SelectQuery {
+			filter.Where(q, filter.Eq("id", "3"))
+			filter.OrWhere(q, filter.Eq("id", "4"))
+			return q
+		}
+		assert.Equal(t, []string{"4"}, ids(either))
+		assert.Equal(t, []string{"3", "4"}, ids(either, dbstore.Unscoped()))
+
+		var got []struct{ Id string }
+		err := repo.FindManyProjected(ctx, (*Post)(nil), &got, nil, nil, either)
+		assert.NoError(t, err)
+		assert.Len(t, got, 1)
+	})
+
+	t.Run("named scopes with arguments", func(t *testing.T) {
+		assert.Equal(t, []string{"1"}, ids(dbstore.WithScope("by_author", "ann")))
+		assert.Equal(t, []string{"4"}, ids(dbstore.WithScope("by_author", "bob")))
+	})
+
+	t.Run("unscoped", func(t *testing.T) {
+		assert.Equal(t, []string{"1", "2", "4"}, ids(dbstore.Unscoped("published")))
+		assert.Equal(t, []string{"1", "3", "4"}, ids(dbstore.Unscoped("not_deleted")))
+		assert.Equal(t, []string{"1", "2", "3"}, ids(dbstore.Unscoped(), dbstore.WithScope("by_author", "ann")))
+		assert.Equal(t, []string{"1", "3"}, ids(dbstore.Unscoped(), dbstore.WithScope("published"), dbstore.WithScope("by_author", "ann")))
+	})
+
+	t.Run("projection", func(t *testing.T) {
+		type postId struct {
+			Id string
+		}
+		byId := func(q *bun.SelectQuery) *bun.SelectQuery { return q.Order("id") }
+
+		var got []postId
+		err := repo.FindManyProjected(ctx, (*Post)(nil), &got, nil, nil, byId)
+		assert.NoError(t, err)
+		assert.Equal(t, []postId{{"1"}, {"4"}}, got)
+
+		got = nil
+		err = repo.FindManyProjected(ctx, (*Post)(nil), &got, nil, nil, byId, dbstore.Unscoped("published"))
+		assert.NoError(t, err)
+		assert.Equal(t, []postId{{"1"}, {"2"}, {"4"}}, got)
+
+		var one postId
+		err = repo.FindOneProjected(ctx, (*Post)(nil), &one, nil, func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("id = ?", "3")
+		})
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+
+		err = repo.FindOneProjected(ctx, (*Post)(nil), &one, nil, dbstore.Unscoped(), func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("id = ?", "3")
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "3", one.Id)
+	})
+
+	t.Run("find or create a hidden record", func(t *testing.T) {
+		post := Post{Id: "3", AuthorId: "ann"}
+		created, err := repo.FindOrCreate(ctx, &post, func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("id = ?", "3")
+		})
+		assert.NoError(t, err)
+		assert.False(t, created)
+		assert.True(t, post.Deleted)
+	})
+
+	t.Run("unscoped outside of the repository", func(t *testing.T) {
+		var got []Post
+		err := db.NewSelect().Model(&got).Apply(dbstore.Unscoped()).Scan(ctx)
+		assert.ErrorContains(t, err, "Unscoped only applies")
+	})
+
+	t.Run("unknown scope", func(t *testing.T) {
+		var got []Post
+		err := repo.FindManyWhere(ctx, &got, nil, dbstore.WithScope("recent"))
+		assert.ErrorContains(t, err, `has no scope "recent"`)
+
+		assert.Panics(t, func() {
+			dbstore.RegisterScope([]Post{}, "by_author", func(...any) dbstore.SelectCriteria { return nil })
+		})
+	})
+}
@@ -0,0 +1,67 @@
+package dbstore
+
+import (
+	"container/list"
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// maxCachedRegexps bounds the patterns kept compiled, as they can come from
+// clients, e.g through filter.Matches.
+const maxCachedRegexps = 256
+
+// regexps caches the patterns compiled by sqliteRegexp, as SQLite calls it
+// once per row, evicting the least recently used.
+var regexps = &regexpCache{entries: make(map[string]*list.Element), order: list.New()}
+
+type regexpCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element // of *regexp.Regexp, by pattern
+	order   *list.List               // most recently used first
+}
+
+func (c *regexpCache) compile(pattern string) (*regexp.Regexp, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[pattern]; ok {
+		c.order.MoveToFront(e)
+		c.mu.Unlock()
+		return e.Value.(*regexp.Regexp), nil
+	}
+	c.mu.Unlock()
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[pattern]; !ok {
+		c.entries[pattern] = c.order.PushFront(re)
+		if c.order.Len() > maxCachedRegexps {
+			oldest := c.order.Back()
+			c.order.Remove(oldest)
+			delete(c.entries, oldest.Value.(*regexp.Regexp).String())
+		}
+	}
+	return re, nil
+}
+
+// sqliteRegexp is the REGEXP function registered on SQLite connections,
+// which SQLite calls as regexp(pattern, value) for "value REGEXP pattern".
+// Patterns use Go's regexp syntax, e.g "(?i)" ignores case.
+func sqliteRegexp(pattern string, value any) (bool, error) {
+	re, err := regexps.compile(pattern)
+	if err != nil {
+		return false, fmt.Errorf("regexp: %w", err)
+	}
+
+	switch v := value.(type) {
+	case string:
+		return re.MatchString(v), nil
+	case []byte:
+		return re.Match(v), nil
+	}
+	return re.MatchString(fmt.Sprint(value)), nil
+}
@@ -0,0 +1,34 @@
+//go:build cgo && (cgosqlite || !((darwin && amd64) || (darwin && arm64) || (linux && 386) || (linux && amd64) || (linux && arm) || (linux && arm64) || (windows && amd64)))
+
+package dbstore
+
+import (
+	"database/sql"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const sqliteRegexpDriver = "sqliteshim_regexp"
+
+func init() {
+	sql.Register(sqliteRegexpDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("regexp", func(pattern, value any) (any, error) {
+				// as with the other SQL operators, NULL gives NULL; the
+				// driver passes it as a nil []byte
+				p, ok := pattern.(string)
+				if b, isBytes := value.([]byte); !ok || value == nil || isBytes && b == nil {
+					return nil, nil
+				}
+				return sqliteRegexp(p, value)
+			}, true)
+		},
+	})
+}
+
+// sqliteDriverName is the github.com/mattn/go-sqlite3 driver, used by
+// sqliteshim on this platform, with the REGEXP function added to every
+// connection.
+func sqliteDriverName() (string, error) {
+	return sqliteRegexpDriver, nil
+}
@@ -0,0 +1,37 @@
+//go:build !cgosqlite && ((darwin && amd64) || (darwin && arm64) || (linux && 386) || (linux && amd64) || (linux && arm) || (linux && arm64) || (windows && amd64))
+
+package dbstore
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"sync"
+
+	"modernc.org/sqlite"
+)
+
+var (
+	registerRegexp    sync.Once
+	registerRegexpErr error
+)
+
+// sqliteDriverName is modernc.org/sqlite, the driver of sqliteshim on this
+// platform, as registered by modernc.org/sqlite itself: unlike the instance
+// sqliteshim registers, it adds the registered REGEXP function to every
+// connection. It fails if another package registered a "regexp" function.
+func sqliteDriverName() (string, error) {
+	registerRegexp.Do(func() {
+		registerRegexpErr = sqlite.RegisterDeterministicScalarFunction("regexp", 2,
+			func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+				pattern, ok := args[0].(string)
+				if !ok || args[1] == nil {
+					return nil, nil
+				}
+				return sqliteRegexp(pattern, args[1])
+			})
+	})
+	if registerRegexpErr != nil {
+		return "", fmt.Errorf("registering the sqlite regexp function: %w", registerRegexpErr)
+	}
+	return "sqlite", nil
+}
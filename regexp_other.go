@@ -0,0 +1,11 @@
+//go:build !cgo && (cgosqlite || !((darwin && amd64) || (darwin && arm64) || (linux && 386) || (linux && amd64) || (linux && arm) || (linux && arm64) || (windows && amd64)))
+
+package dbstore
+
+import "github.com/uptrace/bun/driver/sqliteshim"
+
+// sqliteDriverName is sqliteshim, which has no SQLite driver on this
+// platform, so there is no REGEXP function to register.
+func sqliteDriverName() (string, error) {
+	return sqliteshim.ShimName, nil
+}
@@ -0,0 +1,252 @@
+package dbstore
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// WithRelations eager loads the named bun relations as part of a Find query.
+// Nested relations are given as dotted paths, e.g "Author.Publisher".
+func WithRelations(relations ...string) SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		for _, relation := range relations {
+			q.Relation(relation)
+		}
+		return q
+	}
+}
+
+// LoadRelations loads the named bun relations onto models that were already
+// fetched. modelsPtr is a pointer to a model or to a slice of models.
+//
+// Each has-one, belongs-to and has-many relation is loaded for the whole slice
+// with one query, while m2m relations take one query for the intermediary
+// table and one for the related table. Nested relations are given as dotted
+// paths, e.g "Author.Publisher".
+func LoadRelations(ctx context.Context, db bun.IDB, modelsPtr any, relations ...string) error {
+	v := reflect.ValueOf(modelsPtr)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("load relations: expected a pointer to a model or a slice of models, got %T", modelsPtr)
+	}
+	v = v.Elem()
+
+	var models []reflect.Value
+	switch v.Kind() {
+	case reflect.Struct:
+		models = append(models, v)
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if model := reflect.Indirect(v.Index(i)); model.IsValid() {
+				models = append(models, model)
+			}
+		}
+	default:
+		return fmt.Errorf("load relations: expected a pointer to a model or a slice of models, got %T", modelsPtr)
+	}
+
+	elem := v.Type()
+	for elem.Kind() == reflect.Slice || elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return fmt.Errorf("load relations: expected a pointer to a model or a slice of models, got %T", modelsPtr)
+	}
+
+	table := db.Dialect().Tables().Get(elem)
+	for _, relation := range relations {
+		if err := loadRelation(ctx, db, table, models, relation); err != nil {
+			return fmt.Errorf("load relations: %w", err)
+		}
+	}
+	return nil
+}
+
+// loadRelation loads the first relation of path onto models, then recurses
+// into the related models for the rest of the path.
+func loadRelation(ctx context.Context, db bun.IDB, table *schema.Table, models []reflect.Value, path string) error {
+	name, rest, _ := strings.Cut(path, ".")
+
+	rel, ok := table.Relations[name]
+	if !ok {
+		return fmt.Errorf("%s has no relation %q", table.TypeName, name)
+	}
+	if rel.PolymorphicField != nil {
+		return fmt.Errorf("polymorphic relation %s.%s is not supported", table.TypeName, name)
+	}
+	if len(models) == 0 {
+		return nil
+	}
+
+	var (
+		related map[string][]reflect.Value
+		err     error
+	)
+	if rel.Type == schema.ManyToManyRelation {
+		related, err = queryM2M(ctx, db, rel, models)
+	} else {
+		related, err = queryRelated(ctx, db, rel, fieldsKeys(rel.BaseFields, models))
+	}
+	if err != nil {
+		return err
+	}
+
+	var nested []reflect.Value
+	for _, model := range models {
+		matches := related[fieldsKey(rel.BaseFields, model)]
+		field := rel.Field.Value(model)
+
+		switch rel.Type {
+		case schema.HasManyRelation, schema.ManyToManyRelation:
+			slice := reflect.MakeSlice(field.Type(), 0, len(matches))
+			for _, match := range matches {
+				if field.Type().Elem().Kind() != reflect.Pointer {
+					match = match.Elem()
+				}
+				slice = reflect.Append(slice, match)
+			}
+			field.Set(slice)
+
+			for i := 0; i < field.Len(); i++ {
+				nested = append(nested, reflect.Indirect(field.Index(i)))
+			}
+		default:
+			if len(matches) == 0 {
+				field.Set(reflect.Zero(field.Type()))
+				continue
+			}
+			if field.Kind() == reflect.Pointer {
+				field.Set(matches[0])
+			} else {
+				field.Set(matches[0].Elem())
+			}
+			nested = append(nested, reflect.Indirect(field))
+		}
+	}
+
+	if rest == "" {
+		return nil
+	}
+	return loadRelation(ctx, db, rel.JoinTable, nested, rest)
+}
+
+// queryRelated selects the rows of the relation's join table whose join
+// fields hold one of keys, grouped by those fields.
+func queryRelated(ctx context.Context, db bun.IDB, rel *schema.Relation, keys [][]any) (map[string][]reflect.Value, error) {
+	rows, err := selectWhereIn(ctx, db, rel.JoinTable, rel.JoinFields, keys, rel.Condition)
+	if err != nil {
+		return nil, err
+	}
+
+	related := make(map[string][]reflect.Value, rows.Len())
+	for i := 0; i < rows.Len(); i++ {
+		row := rows.Index(i)
+		key := fieldsKey(rel.JoinFields, row.Elem())
+		related[key] = append(related[key], row)
+	}
+	return related, nil
+}
+
+// queryM2M selects the intermediary rows linking models to the join table,
+// then the join table rows they point at, grouped by the base key.
+func queryM2M(ctx context.Context, db bun.IDB, rel *schema.Relation, models []reflect.Value) (map[string][]reflect.Value, error) {
+	links, err := selectWhereIn(ctx, db, rel.M2MTable, rel.M2MBaseFields, fieldsKeys(rel.BaseFields, models), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var linked []reflect.Value
+	for i := 0; i < links.Len(); i++ {
+		linked = append(linked, links.Index(i).Elem())
+	}
+
+	byJoinKey, err := queryRelated(ctx, db, rel, fieldsKeys(rel.M2MJoinFields, linked))
+	if err != nil {
+		return nil, err
+	}
+
+	related := make(map[string][]reflect.Value, len(linked))
+	for _, link := range linked {
+		key := fieldsKey(rel.M2MBaseFields, link)
+		related[key] = append(related[key], byJoinKey[fieldsKey(rel.M2MJoinFields, link)]...)
+	}
+	return related, nil
+}
+
+// selectWhereIn scans the rows of table whose fields hold one of keys into
+// a new slice of model pointers.
+func selectWhereIn(ctx context.Context, db bun.IDB, table *schema.Table, fields []*schema.Field, keys [][]any, conditions []string) (reflect.Value, error) {
+	rows := reflect.New(reflect.SliceOf(reflect.PointerTo(table.Type)))
+	if len(keys) == 0 {
+		return rows.Elem(), nil
+	}
+
+	q := db.NewSelect().Model(rows.Interface())
+	if len(fields) == 1 {
+		values := make([]any, len(keys))
+		for i := range keys {
+			values[i] = keys[i][0]
+		}
+		q.Where("? IN (?)", bun.Ident(fields[0].Name), bun.In(values))
+	} else {
+		var (
+			columns = make([]string, len(fields))
+			args    = make([]any, 0, len(fields)+1)
+		)
+		for i, field := range fields {
+			columns[i] = "?"
+			args = append(args, bun.Ident(field.Name))
+		}
+		q.Where("("+strings.Join(columns, ", ")+") IN (?)", append(args, bun.In(keys))...)
+	}
+
+	for _, condition := range conditions {
+		q.Where(condition)
+	}
+
+	if err := q.Scan(ctx); err != nil {
+		return reflect.Value{}, err
+	}
+	return rows.Elem(), nil
+}
+
+// fieldsKeys returns the distinct, non-null values of fields across models.
+func fieldsKeys(fields []*schema.Field, models []reflect.Value) [][]any {
+	var (
+		keys = make([][]any, 0, len(models))
+		seen = make(map[string]bool, len(models))
+	)
+
+next:
+	for _, model := range models {
+		key := make([]any, len(fields))
+		for i, field := range fields {
+			value := field.Value(model)
+			if (value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface) && value.IsNil() {
+				continue next
+			}
+			key[i] = reflect.Indirect(value).Interface()
+		}
+
+		if k := fieldsKey(fields, model); !seen[k] {
+			seen[k] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// fieldsKey gives the values of fields a comparable form, so rows of related
+// tables can be matched on columns of differing Go types, e.g an int64 and
+// a sql.NullInt64, or times in different locations.
+func fieldsKey(fields []*schema.Field, model reflect.Value) string {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = fieldString(field, field.Value(model).Interface())
+	}
+	return strings.Join(parts, "\x00")
+}
@@ -0,0 +1,317 @@
+package dbstore
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// FindOrCreate looks up a record with the given criteria and inserts modelPtr
+// when nothing is found. When db is a bun.Tx it runs inside that transaction.
+// It reports whether the record was created. A record hidden by a default
+// scope is not found, so when the insert then conflicts with it, it is read
+// back unscoped.
+func FindOrCreate(ctx context.Context, db bun.IDB, modelPtr any, sc ...SelectCriteria) (bool, error) {
+	var created bool
+
+	err := db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		created = false
+
+		err := findOne(ctx, tx, modelPtr, sc)
+		if err == nil || !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		res, err := tx.NewInsert().Model(modelPtr).Ignore().Exec(ctx)
+		if err != nil {
+			return err
+		}
+
+		if n, err := res.RowsAffected(); err == nil && n > 0 {
+			created = true
+			return nil
+		}
+
+		// a concurrent insert, or a record hidden by a default scope, got
+		// there first: read it back
+		return findOne(ctx, tx, modelPtr, append(sc[:len(sc):len(sc)], Unscoped()))
+	})
+
+	return created, err
+}
+
+func findOne(ctx context.Context, db bun.IDB, modelPtr any, sc []SelectCriteria) error {
+	return ApplySelectCriteria(db.NewSelect().Model(modelPtr), sc...).Limit(1).Scan(ctx)
+}
+
+// CompareAndSwap updates the record identified by the primary key of modelPtr
+// only when its current column values match expected. A nil expected value
+// matches NULL. It reports whether the record was changed, and returns
+// sql.ErrNoRows when it does not exist. modelPtr itself is not refreshed
+// with the new values.
+func CompareAndSwap(ctx context.Context, db bun.IDB, modelPtr any, expected map[string]any, values map[string]any) (bool, error) {
+	if len(values) == 0 {
+		return false, errors.New("compare and swap: no values to set")
+	}
+
+	q := db.NewUpdate().Model(modelPtr).WherePK()
+	for _, column := range sortedKeys(values) {
+		q = q.Set("? = ?", bun.Ident(column), values[column])
+	}
+	for _, column := range sortedKeys(expected) {
+		if expected[column] == nil {
+			q = q.Where("? IS NULL", bun.Ident(column))
+			continue
+		}
+		q = q.Where("? = ?", bun.Ident(column), expected[column])
+	}
+
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n == 1 {
+		return true, nil
+	}
+
+	exists, err := db.NewSelect().Model(modelPtr).WherePK().Exists(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, sql.ErrNoRows
+	}
+	return false, nil
+}
+
+// sortedKeys keeps the generated SQL stable across calls.
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// pkChunkSize bounds the number of keys sent in a single IN list.
+const pkChunkSize = 500
+
+// FindManyByPKs retrieves the records whose primary keys are listed in keys
+// into modelsPtr, a pointer to a slice of models. Keys of a composite primary
+// key are given as []any in the order the key columns are declared.
+// With keepOrder the results follow the order of keys. The keys that matched
+// no record are returned.
+func FindManyByPKs(ctx context.Context, db bun.IDB, modelsPtr any, keys []any, keepOrder bool) ([]any, error) {
+	slice, table, err := pkSliceTable(db, modelsPtr)
+	if err != nil {
+		return nil, err
+	}
+
+	found := reflect.MakeSlice(slice.Type(), 0, len(keys))
+	for start := 0; start < len(keys); start += pkChunkSize {
+		end := min(start+pkChunkSize, len(keys))
+
+		chunk := reflect.New(slice.Type())
+		q := db.NewSelect().Model(chunk.Interface())
+		if err := wherePKIn(q, table, keys[start:end]); err != nil {
+			return nil, err
+		}
+		if err := q.Scan(ctx); err != nil {
+			return nil, err
+		}
+		found = reflect.AppendSlice(found, chunk.Elem())
+	}
+
+	byKey := make(map[string]int, found.Len())
+	for i := 0; i < found.Len(); i++ {
+		byKey[modelPKString(table, found.Index(i))] = i
+	}
+
+	var (
+		missing []any
+		ordered = reflect.MakeSlice(slice.Type(), 0, found.Len())
+		seen    = make(map[string]bool, len(keys))
+	)
+	for _, key := range keys {
+		k := keyString(table, key)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+
+		i, ok := byKey[k]
+		if !ok {
+			missing = append(missing, key)
+			continue
+		}
+		ordered = reflect.Append(ordered, found.Index(i))
+	}
+
+	if keepOrder {
+		found = ordered
+	}
+	slice.Set(found)
+
+	return missing, nil
+}
+
+// DeleteManyByPK deletes the slice of models pointed to by modelsPtr by their
+// primary keys, in chunks, within a single transaction.
+func DeleteManyByPK(ctx context.Context, db bun.IDB, modelsPtr any) error {
+	slice, _, err := pkSliceTable(db, modelsPtr)
+	if err != nil {
+		return err
+	}
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for start := 0; start < slice.Len(); start += pkChunkSize {
+			end := min(start+pkChunkSize, slice.Len())
+
+			chunk := reflect.New(slice.Type())
+			chunk.Elem().Set(slice.Slice(start, end))
+			if _, err := tx.NewDelete().Model(chunk.Interface()).WherePK().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// pkSliceTable validates modelsPtr is a pointer to a slice of models with
+// a primary key, and returns the slice along with the model's table.
+func pkSliceTable(db bun.IDB, modelsPtr any) (reflect.Value, *schema.Table, error) {
+	v := reflect.ValueOf(modelsPtr)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Slice {
+		return reflect.Value{}, nil, fmt.Errorf("expected a pointer to a slice of models, got %T", modelsPtr)
+	}
+
+	elem := v.Elem().Type().Elem()
+	if elem.Kind() == reflect.Pointer {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return reflect.Value{}, nil, fmt.Errorf("expected a pointer to a slice of models, got %T", modelsPtr)
+	}
+
+	table := db.Dialect().Tables().Get(elem)
+	if len(table.PKs) == 0 {
+		return reflect.Value{}, nil, fmt.Errorf("model %s has no primary key", table.TypeName)
+	}
+
+	return v.Elem(), table, nil
+}
+
+// wherePKIn restricts q to keys, using a row-value IN list for composite keys.
+func wherePKIn(q *bun.SelectQuery, table *schema.Table, keys []any) error {
+	if len(table.PKs) == 1 {
+		q.Where("? IN (?)", bun.Ident(table.PKs[0].Name), bun.In(keys))
+		return nil
+	}
+
+	for _, key := range keys {
+		if row, ok := key.([]any); !ok || len(row) != len(table.PKs) {
+			return fmt.Errorf("model %s expects composite keys of %d values, got %v", table.TypeName, len(table.PKs), key)
+		}
+	}
+
+	var (
+		columns = make([]string, len(table.PKs))
+		args    = make([]any, 0, len(table.PKs)+1)
+	)
+	for i, pk := range table.PKs {
+		columns[i] = "?"
+		args = append(args, bun.Ident(pk.Name))
+	}
+	args = append(args, bun.In(keys))
+
+	q.Where("("+strings.Join(columns, ", ")+") IN (?)", args...)
+	return nil
+}
+
+// keyString and modelPKString give a key and a scanned model the same
+// comparable form, so results can be matched back to the requested keys.
+func keyString(table *schema.Table, key any) string {
+	row, ok := key.([]any)
+	if !ok || len(table.PKs) == 1 {
+		row = []any{key}
+	}
+
+	parts := make([]string, len(row))
+	for i := range row {
+		parts[i] = fieldString(table.PKs[i], row[i])
+	}
+	return strings.Join(parts, "\x00")
+}
+
+func modelPKString(table *schema.Table, model reflect.Value) string {
+	strct := reflect.Indirect(model)
+	if strct.Kind() == reflect.Pointer {
+		strct = strct.Elem()
+	}
+
+	parts := make([]string, len(table.PKs))
+	for i, pk := range table.PKs {
+		parts[i] = fieldString(pk, pk.Value(strct).Interface())
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// fieldString formats a value of field so that equal values format the
+// same. Pointers are dereferenced and driver.Valuer values, e.g sql.NullInt64,
+// replaced by their driver value. Values are then converted to the type of the
+// field when that loses nothing, e.g an int key of an int64 field, and times
+// formatted in UTC, whatever their location.
+func fieldString(field *schema.Field, value any) string {
+	v := reflect.ValueOf(value)
+	for {
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return "<nil>"
+			}
+			v = v.Elem()
+		}
+		if !v.IsValid() {
+			return "<nil>"
+		}
+
+		valuer, ok := v.Interface().(driver.Valuer)
+		if !ok {
+			break
+		}
+		value, err := valuer.Value()
+		if err != nil {
+			break
+		}
+		v = reflect.ValueOf(value)
+	}
+
+	typ := field.IndirectType
+	if v.Type() != typ && v.CanConvert(typ) && typ.ConvertibleTo(v.Type()) {
+		if converted := v.Convert(typ); converted.Convert(v.Type()).Equal(v) {
+			v = converted
+		}
+	}
+
+	switch value := v.Interface().(type) {
+	case time.Time:
+		return value.UTC().Format(time.RFC3339Nano)
+	case []byte:
+		return string(value)
+	}
+	return fmt.Sprint(v.Interface())
+}
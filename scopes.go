@@ -0,0 +1,185 @@
+package dbstore
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// ScopeFunc builds the criteria of a scope from the arguments it is
+// applied with, e.g the id of a user for "visible to user X".
+type ScopeFunc func(args ...any) SelectCriteria
+
+type scope struct {
+	name      string
+	fn        ScopeFunc
+	isDefault bool
+}
+
+var (
+	scopesMu sync.RWMutex
+	scopes   = make(map[reflect.Type][]*scope)
+
+	// removedScopes holds the default scopes removed with Unscoped from
+	// the queries being built by ApplySelectCriteria.
+	removedScopes sync.Map // *bun.SelectQuery -> map[string]bool, nil for all
+)
+
+// RegisterScope registers a named scope for the model type of model, e.g
+//
+//	dbstore.RegisterScope((*Book)(nil), "by_author", func(args ...any) dbstore.SelectCriteria {
+//		return func(q *bun.SelectQuery) *bun.SelectQuery {
+//			return q.Where("author_id = ?", args[0])
+//		}
+//	})
+//
+// which WithScope("by_author", id) then applies. Scopes are usually
+// registered from init functions. It panics if the model already has a
+// scope of that name.
+func RegisterScope(model any, name string, fn ScopeFunc) {
+	registerScope(model, &scope{name: name, fn: fn})
+}
+
+// RegisterDefaultScope registers a named scope that the repositories' find
+// methods, e.g FindManyWhere and FindManyProjected, apply to every query on
+// the model type of model, e.g "not deleted", unless it is removed with
+// Unscoped. It can be applied by name with WithScope too.
+func RegisterDefaultScope(model any, name string, criteria SelectCriteria) {
+	registerScope(model, &scope{name: name, fn: func(...any) SelectCriteria { return criteria }, isDefault: true})
+}
+
+func registerScope(model any, s *scope) {
+	typ := modelType(model)
+	if s.fn == nil {
+		panic(fmt.Sprintf("dbstore: scope %q of %s is nil", s.name, typ))
+	}
+
+	scopesMu.Lock()
+	defer scopesMu.Unlock()
+
+	for _, registered := range scopes[typ] {
+		if registered.name == s.name {
+			panic(fmt.Sprintf("dbstore: scope %q of %s is already registered", s.name, typ))
+		}
+	}
+	scopes[typ] = append(scopes[typ], s)
+}
+
+// WithScope applies the named scope of the query's model with args. An
+// unknown scope makes the query fail with an error.
+func WithScope(name string, args ...any) SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		typ := queryModelType(q)
+		s := lookupScope(typ, name)
+		if s == nil {
+			return q.Err(fmt.Errorf("dbstore: %s has no scope %q", typ, name))
+		}
+		if criteria := s.fn(args...); criteria != nil {
+			criteria(q)
+		}
+		return q
+	}
+}
+
+// Unscoped removes the named default scopes, or all of them when no name
+// is given, from a query built by ApplySelectCriteria, e.g FindManyWhere.
+// Applied to any other query, which has no default scopes to remove, it
+// makes the query fail with an error.
+func Unscoped(names ...string) SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		v, ok := removedScopes.Load(q)
+		if !ok {
+			return q.Err(errors.New("dbstore: Unscoped only applies to queries built by ApplySelectCriteria"))
+		}
+
+		removed := v.(map[string]bool)
+		if len(names) == 0 {
+			removed[""] = true
+		}
+		for _, name := range names {
+			removed[name] = true
+		}
+		return q
+	}
+}
+
+// ApplySelectCriteria applies the criteria to the query, skipping nil ones,
+// followed by the default scopes of its model that were not removed with
+// Unscoped. The where clauses of the criteria and of each scope are grouped
+// in parentheses, so a scope applies to the whole of an "OR" of the
+// criteria. Repositories call it for FindOneWhere, FindManyWhere and their
+// projected variants.
+func ApplySelectCriteria(q *bun.SelectQuery, sc ...SelectCriteria) *bun.SelectQuery {
+	removed := make(map[string]bool)
+	removedScopes.Store(q, removed)
+	defer removedScopes.Delete(q)
+
+	q.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+		for i := range sc {
+			if sc[i] == nil {
+				continue
+			}
+			sc[i](q)
+		}
+		return q
+	})
+
+	if removed[""] {
+		return q
+	}
+	for _, s := range defaultScopes(queryModelType(q)) {
+		if removed[s.name] {
+			continue
+		}
+		if criteria := s.fn(); criteria != nil {
+			q.WhereGroup(" AND ", criteria)
+		}
+	}
+	return q
+}
+
+func lookupScope(typ reflect.Type, name string) *scope {
+	scopesMu.RLock()
+	defer scopesMu.RUnlock()
+
+	for _, s := range scopes[typ] {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func defaultScopes(typ reflect.Type) []*scope {
+	scopesMu.RLock()
+	defer scopesMu.RUnlock()
+
+	var defaults []*scope
+	for _, s := range scopes[typ] {
+		if s.isDefault {
+			defaults = append(defaults, s)
+		}
+	}
+	return defaults
+}
+
+// modelType returns the struct type of a model, a pointer to it or a
+// slice of either.
+func modelType(model any) reflect.Type {
+	typ := reflect.TypeOf(model)
+	for typ != nil && (typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice) {
+		typ = typ.Elem()
+	}
+	return typ
+}
+
+func queryModelType(q *bun.SelectQuery) reflect.Type {
+	if tm, ok := q.GetModel().(interface{ Table() *schema.Table }); ok {
+		return tm.Table().Type
+	}
+	return nil
+}
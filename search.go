@@ -0,0 +1,38 @@
+package dbstore
+
+import (
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+// SearchConfig is the Postgres text search configuration of full-text
+// search indexes and queries.
+const SearchConfig = "english"
+
+// SearchVector is the Postgres tsvector of the columns, e.g
+//
+//	to_tsvector('english', coalesce("title", '') || ' ' || coalesce("body", ''))
+//
+// It is shared by the index created by the seeder and the search of the
+// filter package, which must use the same expression for the index to be
+// used. Columns may be qualified, e.g "article.title".
+func SearchVector(columns ...string) schema.QueryAppender {
+	var (
+		query = "to_tsvector(?, "
+		args  = []any{SearchConfig}
+	)
+	for i, column := range columns {
+		if i > 0 {
+			query += " || ' ' || "
+		}
+		query += "coalesce(?, '')"
+		args = append(args, bun.Ident(column))
+	}
+	return schema.SafeQuery(query+")", args)
+}
+
+// SearchTable names the SQLite FTS5 table indexing the columns of a table
+// for full-text search.
+func SearchTable(table string) string {
+	return table + "_fts"
+}
@@ -3,14 +3,19 @@ package seeder
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"strings"
 
+	dbstore "github.com/otyang/go-dbstore"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
 )
 
 var (
-	ErrCreateTablesPrefix     = "create table error: %w"
-	ErrDropTablesPrefix       = "drop table error: %w"
-	ErrDropCreateTablesPrefix = "drop and create tables error: %w"
+	ErrCreateTablesPrefix      = "create table error: %w"
+	ErrDropTablesPrefix        = "drop table error: %w"
+	ErrDropCreateTablesPrefix  = "drop and create tables error: %w"
+	ErrCreateSearchIndexPrefix = "create search index error: %w"
 )
 
 type Seeder struct {
@@ -76,8 +81,77 @@ func (sm *Seeder) DropAndCreateTables(ctx context.Context, models []any, interme
 
 func (sm *Seeder) CreateIndex(ctx context.Context, modelPtr any, indexName string, indexColumn string) error {
 	err := sm.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
-		_, err := sm.db.NewCreateIndex().Model(modelPtr).Index(indexName).Column(indexColumn).Exec(ctx)
+		_, err := tx.NewCreateIndex().Model(modelPtr).Index(indexName).Column(indexColumn).Exec(ctx)
 		return err
 	})
 	return err
 }
+
+// CreateSearchIndex indexes the columns of the model's table for full-text
+// search with filter.Search. On Postgres it creates a GIN index on
+// dbstore.SearchVector. On SQLite it creates the FTS5 table
+// dbstore.SearchTable, filled with the rows of the table and kept in sync
+// by triggers. Both are skipped when they already exist.
+func (sm *Seeder) CreateSearchIndex(ctx context.Context, modelPtr any, columns ...string) error {
+	if len(columns) == 0 {
+		return fmt.Errorf(ErrCreateSearchIndexPrefix, fmt.Errorf("no columns to index"))
+	}
+
+	table := sm.db.Dialect().Tables().Get(reflect.TypeOf(modelPtr))
+	for _, column := range columns {
+		if !table.HasField(column) {
+			return fmt.Errorf(ErrCreateSearchIndexPrefix, fmt.Errorf("%s has no column %q", table.TypeName, column))
+		}
+	}
+
+	var stmts []string
+	if sm.db.Dialect().Name() == dialect.PG {
+		stmts = []string{sm.db.Formatter().FormatQuery("CREATE INDEX IF NOT EXISTS ? ON ? USING GIN ((?))",
+			bun.Ident(table.Name+"_search_idx"), bun.Ident(table.Name), dbstore.SearchVector(columns...))}
+	} else {
+		stmts = sqliteSearchIndex(sm.db, table.Name, columns)
+	}
+
+	err := sm.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, stmt := range stmts {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf(ErrCreateSearchIndexPrefix, err)
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// sqliteSearchIndex returns the statements creating an external content
+// FTS5 table on the columns of table, the triggers keeping it in sync and
+// the rebuild indexing the existing rows.
+func sqliteSearchIndex(db *bun.DB, table string, columns []string) []string {
+	var (
+		fts     = dbstore.SearchTable(table)
+		fmter   = db.Formatter()
+		idents  = make([]string, len(columns))
+		newRow  = make([]string, len(columns))
+		oldRow  = make([]string, len(columns))
+		trigger = func(event string) bun.Ident { return bun.Ident(fts + "_" + event) }
+	)
+	for i, column := range columns {
+		idents[i] = fmter.FormatQuery("?", bun.Ident(column))
+		newRow[i] = "new." + idents[i]
+		oldRow[i] = "old." + idents[i]
+	}
+
+	var (
+		cols    = strings.Join(idents, ", ")
+		insert  = fmter.FormatQuery("INSERT INTO ? (rowid, "+cols+") VALUES (new.rowid, "+strings.Join(newRow, ", ")+");", bun.Ident(fts))
+		discard = fmter.FormatQuery("INSERT INTO ? (?, rowid, "+cols+") VALUES ('delete', old.rowid, "+strings.Join(oldRow, ", ")+");", bun.Ident(fts), bun.Ident(fts))
+	)
+
+	return []string{
+		fmter.FormatQuery("CREATE VIRTUAL TABLE IF NOT EXISTS ? USING fts5("+cols+", content=?, content_rowid='rowid', tokenize='porter unicode61')", bun.Ident(fts), table),
+		fmter.FormatQuery("CREATE TRIGGER IF NOT EXISTS ? AFTER INSERT ON ? BEGIN "+insert+" END", trigger("ai"), bun.Ident(table)),
+		fmter.FormatQuery("CREATE TRIGGER IF NOT EXISTS ? AFTER DELETE ON ? BEGIN "+discard+" END", trigger("ad"), bun.Ident(table)),
+		fmter.FormatQuery("CREATE TRIGGER IF NOT EXISTS ? AFTER UPDATE ON ? BEGIN "+discard+" "+insert+" END", trigger("au"), bun.Ident(table)),
+		fmter.FormatQuery("INSERT INTO ? (?) VALUES ('rebuild')", bun.Ident(fts), bun.Ident(fts)),
+	}
+}
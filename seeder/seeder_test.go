@@ -107,3 +107,55 @@ func TestSeeder_CreateIndex(t *testing.T) {
 	err = seederdb.CreateIndex(ctx, (*Movies)(nil), "animal_id_index", "animal_id_index")
 	assert.NoError(t, err)
 }
+
+func TestSeeder_CreateSearchIndex(t *testing.T) {
+	type Post struct {
+		Id    int64 `bun:",pk"`
+		Title string
+		Body  string
+	}
+
+	ctx, db := setUp(t, test_driver, test_dsn)
+	defer tearDown(t, db, (*Post)(nil))
+	defer db.ExecContext(ctx, "DROP TABLE IF EXISTS posts_fts")
+
+	seederdb := NewSeeder(db)
+
+	err := seederdb.CreateTables(ctx, []any{(*Post)(nil)}, nil)
+	assert.NoError(t, err)
+
+	_, err = db.NewInsert().Model(&Post{Id: 1, Title: "Indexing", Body: "rows before the index"}).Exec(ctx)
+	assert.NoError(t, err)
+
+	err = seederdb.CreateSearchIndex(ctx, (*Post)(nil), "title", "body")
+	assert.NoError(t, err)
+
+	// creating it again is a no-op
+	err = seederdb.CreateSearchIndex(ctx, (*Post)(nil), "title", "body")
+	assert.NoError(t, err)
+
+	err = seederdb.CreateSearchIndex(ctx, (*Post)(nil), "summary")
+	assert.Error(t, err)
+
+	matches := func(query string) []int64 {
+		var ids []int64
+		err := db.NewSelect().Table("posts_fts").Column("rowid").Where("posts_fts MATCH ?", query).Order("rowid").Scan(ctx, &ids)
+		assert.NoError(t, err)
+		return ids
+	}
+
+	assert.Equal(t, []int64{1}, matches("rows"))
+
+	_, err = db.NewInsert().Model(&Post{Id: 2, Title: "Triggers", Body: "keep the index in sync"}).Exec(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, matches("index")) // stemmed, so "Indexing" matches
+
+	_, err = db.NewUpdate().Model(&Post{Id: 1, Title: "Indexing", Body: "rewritten"}).WherePK().Exec(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, matches("rows"))
+	assert.Equal(t, []int64{1}, matches("rewritten"))
+
+	_, err = db.NewDelete().Model(&Post{Id: 2}).WherePK().Exec(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, matches("sync"))
+}
@@ -0,0 +1,126 @@
+package dbstore
+
+import "github.com/uptrace/bun"
+
+// Specification is a rule on a model, e.g "overdue invoice", that can both
+// select the matching records and check a model already loaded in Go. The
+// operators of the filter package implement it.
+type Specification interface {
+	// Criteria adds the rule to a select query.
+	Criteria() SelectCriteria
+
+	// IsSatisfiedBy reports whether the model, a struct or a pointer to
+	// one, satisfies the rule.
+	IsSatisfiedBy(model any) bool
+}
+
+type specification struct {
+	criteria  SelectCriteria
+	predicate func(model any) bool
+}
+
+func (s *specification) Criteria() SelectCriteria {
+	return s.criteria
+}
+
+func (s *specification) IsSatisfiedBy(model any) bool {
+	return s.predicate(model)
+}
+
+// NewSpecification pairs criteria with the predicate checking the same rule
+// in Go, e.g
+//
+//	var OverdueInvoice = dbstore.NewSpecification(
+//		func(q *bun.SelectQuery) *bun.SelectQuery {
+//			return q.Where("paid_at IS NULL").Where("due_at < ?", time.Now())
+//		},
+//		func(inv *Invoice) bool {
+//			return inv.PaidAt == nil && inv.DueAt.Before(time.Now())
+//		},
+//	)
+//
+// Models that are neither a T nor a *T do not satisfy it.
+func NewSpecification[T any](criteria SelectCriteria, predicate func(model *T) bool) Specification {
+	return &specification{
+		criteria: criteria,
+		predicate: func(model any) bool {
+			switch m := model.(type) {
+			case *T:
+				return m != nil && predicate(m)
+			case T:
+				return predicate(&m)
+			}
+			return false
+		},
+	}
+}
+
+// And is satisfied when all the specifications are. Its criteria are
+// grouped in parentheses.
+func And(specs ...Specification) Specification {
+	return &specification{
+		criteria: groupCriteria(" AND ", specs),
+		predicate: func(model any) bool {
+			for _, s := range specs {
+				if !s.IsSatisfiedBy(model) {
+					return false
+				}
+			}
+			return true
+		},
+	}
+}
+
+// Or is satisfied when at least one of the specifications is. Its
+// criteria are grouped in parentheses.
+func Or(specs ...Specification) Specification {
+	return &specification{
+		criteria: groupCriteria(" OR ", specs),
+		predicate: func(model any) bool {
+			for _, s := range specs {
+				if s.IsSatisfiedBy(model) {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+// Not is satisfied when the specification is not. In SQL a comparison
+// with NULL is neither true nor false, so a record can satisfy neither a
+// specification nor its negation while IsSatisfiedBy holds for one of
+// them: negate filter conditions with filter.Not, which follows SQL.
+func Not(spec Specification) Specification {
+	return &specification{
+		criteria: func(q *bun.SelectQuery) *bun.SelectQuery {
+			criteria := spec.Criteria()
+			if criteria == nil {
+				return q
+			}
+			// bun drops the separator of the first where of a group, so
+			// "NOT" needs a where before it
+			return q.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+				return q.Where("1 = 1").WhereGroup(" AND NOT ", criteria)
+			})
+		},
+		predicate: func(model any) bool {
+			return !spec.IsSatisfiedBy(model)
+		},
+	}
+}
+
+// groupCriteria joins the criteria of the specifications with sep, each
+// in its own parentheses, so criteria using WhereOr keep their meaning.
+func groupCriteria(sep string, specs []Specification) SelectCriteria {
+	return func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			for _, s := range specs {
+				if criteria := s.Criteria(); criteria != nil {
+					q.WhereGroup(sep, criteria)
+				}
+			}
+			return q
+		})
+	}
+}
@@ -2,6 +2,8 @@ package xbun
 
 import (
 	"context"
+	"fmt"
+	"reflect"
 
 	dbstore "github.com/otyang/go-dbstore"
 	"github.com/uptrace/bun"
@@ -13,6 +15,16 @@ type (
 	DeleteCriteria func(*bun.DeleteQuery) *bun.DeleteQuery
 )
 
+// selectCriteria converts criteria to dbstore.SelectCriteria, for
+// dbstore.ApplySelectCriteria.
+func selectCriteria(sc []SelectCriteria) []dbstore.SelectCriteria {
+	converted := make([]dbstore.SelectCriteria, len(sc))
+	for i := range sc {
+		converted[i] = dbstore.SelectCriteria(sc[i])
+	}
+	return converted
+}
+
 // Creates a single record. Returns error if it already exists.
 // To silently discard the error set ignoreDuplicate errors to true.
 // Note ignoring duplicates doesnt mean the data will be inserted. it
@@ -40,35 +52,34 @@ func FindOneByPK[T any](ctx context.Context, db bun.IDB, modelPtr *T) error {
 	return db.NewSelect().Model(modelPtr).WherePK().Limit(1).Scan(ctx)
 }
 
+// FindOneWhere retrieves a single record matching the criteria, with the
+// default scopes of its model applied, see dbstore.RegisterDefaultScope.
 func FindOneWhere[T any](ctx context.Context, db bun.IDB, modelPtr *T, sc ...SelectCriteria) error {
-	q := db.NewSelect().Model(modelPtr)
-
-	for i := range sc {
-		if sc[i] == nil {
-			continue
-		}
-		sc[i](q)
-	}
-
+	q := dbstore.ApplySelectCriteria(db.NewSelect().Model(modelPtr), selectCriteria(sc)...)
 	return q.Limit(1).Scan(ctx)
 }
 
+// FindManyWhere retrieves the records matching the criteria, with the
+// default scopes of their model applied, see dbstore.RegisterDefaultScope.
 func FindManyWhere[T any](ctx context.Context, db bun.IDB, opt dbstore.PaginationOption, sc ...SelectCriteria) ([]T, error) {
 	var modelsPtr []T
 
-	q := db.NewSelect().Model(&modelsPtr)
-	for i := range sc {
-		if sc[i] == nil {
-			continue
-		}
-		sc[i](q)
+	q := dbstore.ApplySelectCriteria(db.NewSelect().Model(&modelsPtr), selectCriteria(sc)...)
+
+	q, err := paginate(q, opt)
+	if err != nil {
+		return nil, err
 	}
 
+	if err := q.Scan(ctx); err != nil {
+		return nil, err
+	}
+	return modelsPtr, nil
+}
+
+func paginate(q *bun.SelectQuery, opt dbstore.PaginationOption) (*bun.SelectQuery, error) {
 	if opt == nil {
-		if err := q.Scan(ctx); err != nil {
-			return nil, err
-		}
-		return modelsPtr, nil
+		return q, nil
 	}
 
 	o := dbstore.PaginationParams{}
@@ -81,18 +92,77 @@ func FindManyWhere[T any](ctx context.Context, db bun.IDB, opt dbstore.Paginatio
 		if o.CursorValue != "" {
 			q = q.Where("? >= ?", bun.Ident(o.CursorColumn), o.CursorValue)
 		}
-		err := q.Scan(ctx)
-
-		return modelsPtr, err
+		return q, nil
 	}
 
 	q = q.OrderExpr(o.CursorColumn + " DESC").Limit(o.Limit)
 	if o.CursorValue != "" {
 		q = q.Where("? <= ?", bun.Ident(o.CursorColumn), o.CursorValue)
 	}
-	err := q.Scan(ctx)
+	return q, nil
+}
+
+// FindOneProjected retrieves a single record of model M matching the criteria,
+// scanning only the projected columns into a D. When columns is empty they are
+// derived from the bun tags of D. Default scopes are applied as with
+// FindOneWhere.
+func FindOneProjected[M any, D any](ctx context.Context, db bun.IDB, columns []string, sc ...SelectCriteria) (D, error) {
+	var dest D
+
+	q, err := projectedQuery[M, D](db, columns, sc)
+	if err != nil {
+		return dest, err
+	}
 
-	return modelsPtr, err
+	err = q.Limit(1).Scan(ctx, &dest)
+	return dest, err
+}
+
+// FindManyProjected retrieves the records of model M matching the criteria,
+// scanning only the projected columns into a slice of D. When columns is empty
+// they are derived from the bun tags of D. Default scopes are applied as with
+// FindManyWhere.
+func FindManyProjected[M any, D any](ctx context.Context, db bun.IDB, columns []string, opt dbstore.PaginationOption, sc ...SelectCriteria) ([]D, error) {
+	q, err := projectedQuery[M, D](db, columns, sc)
+	if err != nil {
+		return nil, err
+	}
+
+	q, err = paginate(q, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	var dest []D
+	if err := q.Scan(ctx, &dest); err != nil {
+		return nil, err
+	}
+	return dest, nil
+}
+
+func projectedQuery[M any, D any](db bun.IDB, columns []string, sc []SelectCriteria) (*bun.SelectQuery, error) {
+	model := db.Dialect().Tables().Get(reflect.TypeOf((*M)(nil)).Elem())
+
+	if len(columns) == 0 {
+		typ := reflect.TypeOf((*D)(nil)).Elem()
+		if typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		if typ.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("projection: cannot derive columns from %s", typ)
+		}
+		for _, field := range db.Dialect().Tables().Get(typ).Fields {
+			columns = append(columns, field.Name)
+		}
+	}
+
+	for _, column := range columns {
+		if !model.HasField(column) {
+			return nil, fmt.Errorf("projection: %q is not a column of %s", column, model.TypeName)
+		}
+	}
+
+	return dbstore.ApplySelectCriteria(db.NewSelect().Model((*M)(nil)).Column(columns...), selectCriteria(sc)...), nil
 }
 
 func UpdateOneByPK[T any](ctx context.Context, db bun.IDB, modelPtr *T) error {
@@ -142,3 +212,40 @@ func DeleteWhere[T any](ctx context.Context, db bun.IDB, modelPtr *T, dc ...Dele
 func Transaction(ctx context.Context, db *bun.DB, fn func(ctx context.Context, tx bun.Tx) error) error {
 	return db.RunInTx(ctx, nil, fn)
 }
+
+// LoadRelations loads the named relations onto models that were already fetched.
+func LoadRelations[T any](ctx context.Context, db bun.IDB, models *[]T, relations ...string) error {
+	return dbstore.LoadRelations(ctx, db, models, relations...)
+}
+
+// FindOrCreate looks up a record with the given criteria and inserts model
+// when nothing is found, see dbstore.FindOrCreate. When db is a bun.Tx it
+// runs inside that transaction.
+func FindOrCreate[T any](ctx context.Context, db bun.IDB, model *T, sc ...SelectCriteria) (bool, error) {
+	return dbstore.FindOrCreate(ctx, db, model, selectCriteria(sc)...)
+}
+
+// CompareAndSwap updates the record identified by the primary key of modelPtr
+// only when its current column values match expected, see
+// dbstore.CompareAndSwap.
+func CompareAndSwap[T any](ctx context.Context, db bun.IDB, modelPtr *T, expected map[string]any, values map[string]any) (bool, error) {
+	return dbstore.CompareAndSwap(ctx, db, modelPtr, expected, values)
+}
+
+// FindManyByPKs retrieves the records whose primary keys are listed in keys,
+// see dbstore.FindManyByPKs. The keys that matched no record are returned
+// alongside the results.
+func FindManyByPKs[T any](ctx context.Context, db bun.IDB, keys []any, keepOrder bool) ([]T, []any, error) {
+	var found []T
+	missing, err := dbstore.FindManyByPKs(ctx, db, &found, keys, keepOrder)
+	if err != nil {
+		return nil, nil, err
+	}
+	return found, missing, nil
+}
+
+// DeleteManyByPK deletes the models by their primary keys, in chunks,
+// within a single transaction.
+func DeleteManyByPK[T any](ctx context.Context, db bun.IDB, modelsPtr *[]T) error {
+	return dbstore.DeleteManyByPK(ctx, db, modelsPtr)
+}
@@ -305,3 +305,187 @@ func TestRepository_Transaction(t *testing.T) {
 		assert.Error(t, err)
 	})
 }
+
+func TestRepository_FindOrCreate(t *testing.T) {
+	var (
+		ctx, db, tearDown = setUpMigrateAndTearDown(t, (*Book)(nil))
+		err               = CreateBulk(ctx, db, &seed, false)
+	)
+
+	defer tearDown()
+	assert.NoError(t, err)
+
+	byId := func(id string) SelectCriteria {
+		return func(q *bun.SelectQuery) *bun.SelectQuery {
+			filter.Where(q, filter.Equal("id", id))
+			return q
+		}
+	}
+
+	got := Book{Id: "2", Title: "should not be inserted"}
+	created, err := FindOrCreate(ctx, db, &got, byId("2"))
+	assert.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, seed[1], got)
+
+	got = Book{Id: "5", Title: "Title 5"}
+	created, err = FindOrCreate(ctx, db, &got, byId("5"))
+	assert.NoError(t, err)
+	assert.True(t, created)
+
+	found := Book{Id: "5"}
+	err = FindOneByPK(ctx, db, &found)
+	assert.NoError(t, err)
+	assert.Equal(t, "Title 5", found.Title)
+}
+
+func TestRepository_CompareAndSwap(t *testing.T) {
+	var (
+		ctx, db, tearDown = setUpMigrateAndTearDown(t, (*Book)(nil))
+		err               = CreateBulk(ctx, db, &seed, false)
+	)
+
+	defer tearDown()
+	assert.NoError(t, err)
+
+	var (
+		expected = map[string]any{"title": "Title 2"}
+		values   = map[string]any{"title": "Swapped Title 2"}
+	)
+
+	swapped, err := CompareAndSwap(ctx, db, &Book{Id: "2"}, expected, values)
+	assert.NoError(t, err)
+	assert.True(t, swapped)
+
+	swapped, err = CompareAndSwap(ctx, db, &Book{Id: "2"}, expected, values)
+	assert.NoError(t, err)
+	assert.False(t, swapped)
+
+	swapped, err = CompareAndSwap(ctx, db, &Book{Id: "404"}, expected, values)
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	assert.False(t, swapped)
+}
+
+func TestRepository_FindManyByPKs(t *testing.T) {
+	var (
+		ctx, db, tearDown = setUpMigrateAndTearDown(t, (*Book)(nil))
+		err               = CreateBulk(ctx, db, &seed, false)
+	)
+
+	defer tearDown()
+	assert.NoError(t, err)
+
+	got, missing, err := FindManyByPKs[Book](ctx, db, []any{"4", "404", "2"}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []Book{seed[3], seed[1]}, got)
+	assert.Equal(t, []any{"404"}, missing)
+
+	got, missing, err = FindManyByPKs[Book](ctx, db, []any{"4", "2"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []Book{seed[1], seed[3]}, got)
+	assert.Empty(t, missing)
+
+	// keys are matched whatever their Go type
+	type Title string
+	id := "3"
+	got, missing, err = FindManyByPKs[Book](ctx, db, []any{&id, Title("1")}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []Book{seed[2], seed[0]}, got)
+	assert.Empty(t, missing)
+}
+
+func TestRepository_DeleteManyByPK(t *testing.T) {
+	var (
+		ctx, db, tearDown = setUpMigrateAndTearDown(t, (*Book)(nil))
+		err               = CreateBulk(ctx, db, &seed, false)
+	)
+
+	defer tearDown()
+	assert.NoError(t, err)
+
+	err = DeleteManyByPK(ctx, db, &[]Book{seed[0], seed[2]})
+	assert.NoError(t, err)
+
+	got, err := FindManyWhere[Book](ctx, db, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []Book{seed[1], seed[3]}, got)
+}
+
+func TestRepository_Projected(t *testing.T) {
+	type BookTitle struct {
+		Title string
+	}
+
+	var (
+		ctx, db, tearDown = setUpMigrateAndTearDown(t, (*Book)(nil))
+		err               = CreateBulk(ctx, db, &seed, false)
+	)
+
+	defer tearDown()
+	assert.NoError(t, err)
+
+	got, err := FindOneProjected[Book, BookTitle](ctx, db, nil, func(q *bun.SelectQuery) *bun.SelectQuery {
+		filter.Where(q, filter.Equal("id", "3"))
+		return q
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, BookTitle{Title: seed[2].Title}, got)
+
+	ids, err := FindManyProjected[Book, Book](ctx, db, []string{"id"}, dbstore.WithCursor(2, false, "id", ""))
+	assert.NoError(t, err)
+	assert.Equal(t, []Book{{Id: "4"}, {Id: "3"}}, ids)
+}
+
+type Note struct {
+	Id       string `bun:",pk"`
+	Archived bool
+}
+
+func init() {
+	dbstore.RegisterDefaultScope((*Note)(nil), "not_archived", func(q *bun.SelectQuery) *bun.SelectQuery {
+		return q.Where("archived = ?", false)
+	})
+}
+
+func TestRepository_DefaultScopes(t *testing.T) {
+	var (
+		ctx, db, tearDown = setUpMigrateAndTearDown(t, (*Note)(nil))
+		notes             = []Note{{Id: "1"}, {Id: "2", Archived: true}, {Id: "3"}}
+		err               = CreateBulk(ctx, db, &notes, false)
+	)
+
+	defer tearDown()
+	assert.NoError(t, err)
+
+	byId := func(id string) SelectCriteria {
+		return func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("id = ?", id)
+		}
+	}
+	orderById := func(q *bun.SelectQuery) *bun.SelectQuery { return q.Order("id") }
+
+	t.Run("projection", func(t *testing.T) {
+		got, err := FindManyProjected[Note, Note](ctx, db, []string{"id"}, nil, orderById)
+		assert.NoError(t, err)
+		assert.Equal(t, []Note{{Id: "1"}, {Id: "3"}}, got)
+
+		got, err = FindManyProjected[Note, Note](ctx, db, []string{"id"}, nil, orderById, SelectCriteria(dbstore.Unscoped()))
+		assert.NoError(t, err)
+		assert.Equal(t, []Note{{Id: "1"}, {Id: "2"}, {Id: "3"}}, got)
+
+		_, err = FindOneProjected[Note, Note](ctx, db, nil, byId("2"))
+		assert.ErrorIs(t, err, sql.ErrNoRows)
+
+		one, err := FindOneProjected[Note, Note](ctx, db, nil, byId("2"), SelectCriteria(dbstore.Unscoped("not_archived")))
+		assert.NoError(t, err)
+		assert.Equal(t, notes[1], one)
+	})
+
+	t.Run("find or create a hidden record", func(t *testing.T) {
+		got := Note{Id: "2"}
+		created, err := FindOrCreate(ctx, db, &got, byId("2"))
+		assert.NoError(t, err)
+		assert.False(t, created)
+		assert.Equal(t, notes[1], got)
+	})
+}